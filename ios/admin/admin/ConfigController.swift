@@ -83,11 +83,27 @@ class ConfigController: PLViewController {
 		alert.addAction(UIAlertAction(title: "检测激光", style: .default, handler: { (action) in
 			self.performSegue(withIdentifier: "ShowQuickCheck", sender: nil)
 			}));
+		alert.addAction(UIAlertAction(title: "设置场地", style: .default, handler: { (action) in
+			self.showArenaInput()
+			}));
 		alert.addAction(UIAlertAction(title: "取消", style: .cancel, handler: nil));
 		alert.popoverPresentationController?.sourceView = sender.view;
 		present(alert, animated: true, completion: nil)
 	}
 
+	func showArenaInput() {
+		let alert = UIAlertController(title: "设置场地", message: "留空使用默认场地", preferredStyle: .alert)
+		alert.addTextField { (textField) in
+			textField.text = Defaults[.arena]
+		}
+		alert.addAction(UIAlertAction(title: "确定", style: .default, handler: { (action) in
+			Defaults[.arena] = alert.textFields?.first?.text ?? ""
+			WsClient.singleton.connect(PLConstants.getWsAddress())
+			}));
+		alert.addAction(UIAlertAction(title: "取消", style: .cancel, handler: nil));
+		present(alert, animated: true, completion: nil)
+	}
+
 	override func viewDidLoad() {
 		super.viewDidLoad()
 		idTextField.placeholder = Defaults[.deviceID]
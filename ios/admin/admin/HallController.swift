@@ -58,7 +58,8 @@ class HallController: PLViewController {
 		guard let team = topTeam else {
 			return
 		}
-		let mode = team.mode == "g" ? "s" : "g"
+		let modes = ["g", "s", "p"]
+		let mode = modes[((modes.index(of: team.mode) ?? -1) + 1) % modes.count]
 		let json = JSON([
 			"cmd": "teamChangeMode",
 			"teamID": topTeam!.id,
@@ -173,6 +174,9 @@ class HallController: PLViewController {
 		if topTeam!.mode == "g" {
 			modeImageView.image = UIImage(named: "FunIcon")
 			modeLabel.text = "[赏金模式]"
+		} else if topTeam!.mode == "p" {
+			modeImageView.image = UIImage(named: "FunIcon")
+			modeLabel.text = "[练习模式]"
 		} else {
 			modeImageView.image = UIImage(named: "SurvivalIcon")
 			modeLabel.text = "[生存模式]"
@@ -33,6 +33,7 @@ class MatchController: PLViewController {
 	var laserViews: [UIView]!
 
 	var mapView: UIImageView = UIImageView()
+	var pauseButton: UIButton = UIButton(type: .system)
 
 	@IBAction func forceEnd() {
 		let json = JSON([
@@ -42,8 +43,24 @@ class MatchController: PLViewController {
 		WsClient.singleton.sendJSON(json)
 	}
 
+	func togglePause() {
+		let json = JSON([
+			"cmd": match?.stage == "paused" ? "resumeMatch" : "pauseMatch",
+			"matchID": Defaults[.matchID]
+		])
+		WsClient.singleton.sendJSON(json)
+	}
+
 	override func viewDidLoad() {
 		super.viewDidLoad()
+		pauseButton.setTitle("暂停", for: UIControlState())
+		pauseButton.addTarget(self, action: #selector(togglePause), for: .touchUpInside)
+		pauseButton.isHidden = true
+		view.addSubview(pauseButton)
+		pauseButton <- [
+			Top(10).to(matchStatusLabel, .bottom),
+			Left().to(matchStatusLabel, .left)
+		]
 		playerTableView.backgroundColor = UIColor.clear
 		playerViews = [UIButton]()
 		laserViews = [UIView]()
@@ -90,7 +107,10 @@ class MatchController: PLViewController {
 			let min = Int(match!.elasped) / 60
 			let sec = Int(match!.elasped) % 60
 			matchTimeLabel.text = String(format: "%02d:%02d", min, sec)
-			matchStatusLabel.text = "实时状态: 进行中"
+			let paused = match!.stage == "paused"
+			matchStatusLabel.text = paused ? "实时状态: 已暂停" : "实时状态: 进行中"
+			pauseButton.setTitle(paused ? "继续" : "暂停", for: UIControlState())
+			pauseButton.isHidden = false
 			playerCountLabel.text = "玩家人数:\(match!.member.count)"
 			totalCoinLabel.text = "总金币:\(match!.gold!)G"
 			energyLabel.text = String(format: "%.1f/%d", match!.energy, match!.maxEnergy)
@@ -133,6 +153,7 @@ class MatchController: PLViewController {
 		} else {
 			matchTimeLabel.text = "00: 00"
 			matchStatusLabel.text = "实时状态: 未进行"
+			pauseButton.isHidden = true
 			playerCountLabel.text = "玩家人数: 0"
 			totalCoinLabel.text = "总金币:0G"
 			energyLabel.text = ""
@@ -21,7 +21,11 @@ struct PLConstants {
 	}
 	static func getHttpAddress(_ path: String) -> String {
 		let p = path.hasPrefix("/") ? path : "/" + path
-		return "http://" + getHost() + p
+		if Defaults[.arena] == "" {
+			return "http://" + getHost() + p
+		}
+		let arena = Defaults[.arena].addingPercentEncoding(withAllowedCharacters: .urlQueryAllowed) ?? ""
+		return "http://" + getHost() + p + "?arena=" + arena
 	}
 	static func getWebsiteAddress(_ path: String) -> String {
 		let p = path.hasPrefix("/") ? path : "/" + path
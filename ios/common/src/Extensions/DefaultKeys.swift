@@ -16,4 +16,5 @@ extension DefaultsKeys {
 	static let matchID = DefaultsKey<Int>("matchID")
 	static let qCount = DefaultsKey<Int>("qCount")
 	static let websiteHost = DefaultsKey<String>("websiteHost")
+	static let arena = DefaultsKey<String>("arena")
 }
@@ -9,6 +9,21 @@
 import Foundation
 import ObjectMapper
 
+class AchievementData: Mappable {
+	var key: String!
+	var name: String!
+	var description: String?
+
+	required init?(map: Map) {
+	}
+
+	func mapping(map: Map) {
+		key <- map["key"]
+		name <- map["name"]
+		description <- map["description"]
+	}
+}
+
 class PlayerData: Mappable {
 	var id: UInt!
 	var createdAt: String!
@@ -25,8 +40,10 @@ class PlayerData: Mappable {
 	var answered: Int!
 	var questionCount: Int!
 	var eid: String?
+	var side: Int = 0 // 对战模式中的队伍, 1红方 2蓝方
 	var level: String? // 玩家级别
 	var url: String? // 本次游戏玩家专属的url
+	var achievements: [AchievementData] = [] // 本局获得的成就
 	required init?(map: Map) {
 	}
 
@@ -47,6 +64,8 @@ class PlayerData: Mappable {
 		answered <- map["answered"]
 		questionCount <- map["questionCount"]
 		eid <- map["eid"]
+		side <- map["side"]
+		achievements <- map["achievements"]
 	}
 
 	func getName() -> String {
@@ -58,6 +77,29 @@ class PlayerData: Mappable {
 	}
 }
 
+class SideData: Mappable {
+	var side: Int!
+	var gold: Int!
+	var rampageCount: Int!
+	var grade: String!
+	var win: Bool = false
+
+	required init?(map: Map) {
+	}
+
+	func mapping(map: Map) {
+		side <- map["side"]
+		gold <- map["gold"]
+		rampageCount <- map["rampageCount"]
+		grade <- map["grade"]
+		win <- map["win"]
+	}
+
+	func getName() -> String {
+		return side == 1 ? "红方" : "蓝方"
+	}
+}
+
 enum MatchAnswerType: Int {
 	case notAnswer = 0, answering, answered
 }
@@ -75,6 +117,7 @@ class MatchData: Mappable {
 	var teamID: String!
 	var eid: String?
 	var grade: String!
+	var sides: [SideData]?
 
 	required init?(map: Map) {
 	}
@@ -83,6 +126,7 @@ class MatchData: Mappable {
 		id <- map["id"]
 		createdAt <- map["createdAt"]
 		mode <- map["mode"]
+		sides <- map["sides"]
 		elasped <- map["elasped"]
 		gold <- map["gold"]
 		member <- map["member"]
@@ -92,6 +136,9 @@ class MatchData: Mappable {
 		eid <- map["eid"]
 		grade <- map["grade"]
 		member.sort { (p1, p2) -> Bool in
+			if p1.side != p2.side {
+				return p1.side < p2.side
+			}
 			return p1.cid.compare(p2.cid) == .orderedAscending
 		}
 	}
@@ -16,10 +16,18 @@ class MatchResultCell: UITableViewCell {
 	@IBOutlet weak var energyLabel: UILabel!
 	@IBOutlet weak var comboLabel: UILabel!
 	@IBOutlet weak var energyIcon: UIImageView!
+	var achievementLabel: UILabel!
 
 	override func awakeFromNib() {
 		super.awakeFromNib()
 		backgroundColor = UIColor.clear
+		achievementLabel = UILabel()
+		achievementLabel.font = UIFont.systemFont(ofSize: 12)
+		achievementLabel.textColor = UIColor(red: 1, green: 204 / 255.0, blue: 0, alpha: 1)
+		achievementLabel.translatesAutoresizingMaskIntoConstraints = false
+		contentView.addSubview(achievementLabel)
+		achievementLabel.leadingAnchor.constraint(equalTo: idLabel.leadingAnchor).isActive = true
+		achievementLabel.topAnchor.constraint(equalTo: idLabel.bottomAnchor, constant: 2).isActive = true
 	}
 
 	func setData(_ data: PlayerData?, current: Bool) {
@@ -39,6 +47,11 @@ class MatchResultCell: UITableViewCell {
 		comboLabel.textColor = c
 		if let d = data {
 			idLabel.text = d.getName()
+			if d.side == 1 {
+				idLabel.textColor = UIColor(red: 1, green: 77 / 255.0, blue: 77 / 255.0, alpha: 1)
+			} else if d.side == 2 {
+				idLabel.textColor = UIColor(red: 77 / 255.0, green: 166 / 255.0, blue: 1, alpha: 1)
+			}
 			if let lvl = d.level {
 				levelLabel.text = "LEVEL.\(lvl)"
 			} else {
@@ -48,6 +61,7 @@ class MatchResultCell: UITableViewCell {
 			goldLabel.text = "\(d.gold!)/\(d.lostGold!)"
 			energyLabel.text = "\(Int(d.energy!))"
 			comboLabel.text = "\(d.combo!)"
+			achievementLabel.text = d.achievements.map { $0.name }.joined(separator: " · ")
 		} else {
 			idLabel.text = "--"
 			levelLabel.text = "--"
@@ -55,6 +69,7 @@ class MatchResultCell: UITableViewCell {
 			goldLabel.text = "--"
 			energyLabel.text = "--"
 			comboLabel.text = "--"
+			achievementLabel.text = ""
 		}
 	}
 }
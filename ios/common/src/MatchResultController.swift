@@ -186,7 +186,13 @@ class MatchResultController: PLViewController {
 	func renderData() {
 		if let data = matchData {
 			HUD.hide()
-			if data.mode == "g" {
+			if data.mode == "v", let sides = data.sides, sides.count == 2 {
+				headerImageView.image = UIImage(named: "FunImage")
+				tableHeaderImageView.image = UIImage(named: "MatchGoldResultHeader")
+				scoreLabel.text = sides.map { side in
+					"\(side.getName()) \(side.gold!)G" + (side.win ? " WIN" : "")
+				}.joined(separator: " : ")
+			} else if data.mode == "g" {
 				headerImageView.image = UIImage(named: "FunImage")
 				tableHeaderImageView.image = UIImage(named: "MatchGoldResultHeader")
 				scoreLabel.text = "\(data.gold!)G"
@@ -105,6 +105,7 @@ extension WsClient: WebSocketDelegate {
 			"cmd": "init",
 			"ID": Defaults[.deviceID],
 			"TYPE": Defaults[.socketType],
+			"ARENA": Defaults[.arena],
 		])
 		self.sendJSON(json)
 	}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"challenger/server/core"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"os"
+	"strconv"
+)
+
+// ServerConfig holds everything that differs between deployments.
+// Values are resolved in order: defaults, the [server] section of the
+// config file, environment variables and finally command line flags.
+type ServerConfig struct {
+	Host        string `toml:"host"`
+	HttpPort    int    `toml:"httpPort"`
+	TcpPort     int    `toml:"tcpPort"`
+	UdpPort     int    `toml:"udpPort"`
+	PprofAddr   string `toml:"pprofAddr"` // empty disables pprof
+	DBPath      string `toml:"dbPath"`
+	IsSimulator bool   `toml:"simulator"`
+	TestRank    bool   `toml:"testRank"`
+	// the other config files, cfg.toml itself is chosen by -config
+	WarmupFile    string `toml:"warmupFile"`
+	SurveyFile    string `toml:"surveyFile"`
+	LaserPairFile string `toml:"laserPairFile"`
+	// optional, without it no achievement is given
+	AchievementsFile string `toml:"achievementsFile"`
+	ConfigFile       string `toml:"-"`
+	RecordDir        string `toml:"recordDir"` // empty disables match recording
+	// Arenas served by this server, without any the files and ports above
+	// make the only arena
+	Arenas []ArenaConfig `toml:"arenas"`
+}
+
+// ArenaConfig is one [[server.arenas]], files left out are the ones of the
+// [server] section except config and laserPairFile which every arena must
+// have on its own
+type ArenaConfig struct {
+	Name             string `toml:"name"`
+	ConfigFile       string `toml:"config"`
+	WarmupFile       string `toml:"warmupFile"`
+	SurveyFile       string `toml:"surveyFile"`
+	LaserPairFile    string `toml:"laserPairFile"`
+	AchievementsFile string `toml:"achievementsFile"`
+	TcpPort          int    `toml:"tcpPort"`
+	UdpPort          int    `toml:"udpPort"`
+}
+
+const defaultConfigFile = "cfg.toml"
+
+func DefaultServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Host:             "10.0.0.11",
+		HttpPort:         3000,
+		TcpPort:          4000,
+		UdpPort:          5000,
+		PprofAddr:        ":8081",
+		DBPath:           "./challenger.db",
+		IsSimulator:      false,
+		TestRank:         true,
+		WarmupFile:       "warmup.toml",
+		SurveyFile:       "survey.toml",
+		LaserPairFile:    "laser.json",
+		AchievementsFile: "achievements.toml",
+		ConfigFile:       defaultConfigFile,
+		RecordDir:        "records",
+	}
+}
+
+func (c *ServerConfig) HttpAddr() string {
+	return fmt.Sprintf("%v:%d", c.Host, c.HttpPort)
+}
+
+// ArenaConfigs is Arenas with the files of the [server] section filled in,
+// or the only arena made of the [server] section
+func (c *ServerConfig) ArenaConfigs() ([]ArenaConfig, error) {
+	if len(c.Arenas) == 0 {
+		return []ArenaConfig{{"", c.ConfigFile, c.WarmupFile, c.SurveyFile, c.LaserPairFile, c.AchievementsFile, c.TcpPort, c.UdpPort}}, nil
+	}
+	arenas := make([]ArenaConfig, len(c.Arenas))
+	for i, a := range c.Arenas {
+		if a.Name == "" || a.ConfigFile == "" || a.LaserPairFile == "" || a.TcpPort == 0 || a.UdpPort == 0 {
+			return nil, fmt.Errorf("arenas[%d] must have name, config, laserPairFile, tcpPort and udpPort", i)
+		}
+		if a.WarmupFile == "" {
+			a.WarmupFile = c.WarmupFile
+		}
+		if a.SurveyFile == "" {
+			a.SurveyFile = c.SurveyFile
+		}
+		if a.AchievementsFile == "" {
+			a.AchievementsFile = c.AchievementsFile
+		}
+		arenas[i] = a
+	}
+	return arenas, nil
+}
+
+func (c *ServerConfig) ArenaTcpAddr(a ArenaConfig) string {
+	return fmt.Sprintf("%v:%d", c.Host, a.TcpPort)
+}
+
+func (c *ServerConfig) ArenaUdpAddr(a ArenaConfig) string {
+	return fmt.Sprintf("%v:%d", c.Host, a.UdpPort)
+}
+
+func (a ArenaConfig) ConfigPaths() core.ConfigPaths {
+	return core.ConfigPaths{
+		Cfg:          a.ConfigFile,
+		Warmup:       a.WarmupFile,
+		Survey:       a.SurveyFile,
+		LaserPair:    a.LaserPairFile,
+		Achievements: a.AchievementsFile,
+	}
+}
+
+// LoadServerConfig parses args (without the program name) and builds the
+// effective configuration. The remaining non-flag arguments are returned.
+func LoadServerConfig(args []string) (*ServerConfig, []string, error) {
+	fs := flag.NewFlagSet("challenger", flag.ContinueOnError)
+	fc := DefaultServerConfig()
+	configFile := fs.String("config", envStr("CHALLENGER_CONFIG", defaultConfigFile), "config file with an optional [server] section")
+	fs.StringVar(&fc.Host, "host", fc.Host, "listen host")
+	fs.IntVar(&fc.HttpPort, "http-port", fc.HttpPort, "http listen port")
+	fs.IntVar(&fc.TcpPort, "tcp-port", fc.TcpPort, "tcp listen port for arduinos")
+	fs.IntVar(&fc.UdpPort, "udp-port", fc.UdpPort, "udp listen port for wearables")
+	fs.StringVar(&fc.PprofAddr, "pprof", fc.PprofAddr, "pprof listen address, empty to disable")
+	fs.StringVar(&fc.DBPath, "db", fc.DBPath, "sqlite database path")
+	fs.BoolVar(&fc.IsSimulator, "simulator", fc.IsSimulator, "run with simulated players and lasers")
+	fs.BoolVar(&fc.TestRank, "test-rank", fc.TestRank, "serve rank test data from ranktest.json")
+	fs.StringVar(&fc.WarmupFile, "warmup", fc.WarmupFile, "warmup config file")
+	fs.StringVar(&fc.SurveyFile, "survey", fc.SurveyFile, "survey config file")
+	fs.StringVar(&fc.LaserPairFile, "laser-pair", fc.LaserPairFile, "laser pair file")
+	fs.StringVar(&fc.AchievementsFile, "achievements", fc.AchievementsFile, "achievements file")
+	fs.StringVar(&fc.RecordDir, "record-dir", fc.RecordDir, "directory of match recordings, empty to disable")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	c := DefaultServerConfig()
+	c.ConfigFile = *configFile
+	if err := c.loadFile(*configFile); err != nil {
+		return nil, nil, err
+	}
+	if err := c.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+	// only flags given explicitly override file and env values
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			c.Host = fc.Host
+		case "http-port":
+			c.HttpPort = fc.HttpPort
+		case "tcp-port":
+			c.TcpPort = fc.TcpPort
+		case "udp-port":
+			c.UdpPort = fc.UdpPort
+		case "pprof":
+			c.PprofAddr = fc.PprofAddr
+		case "db":
+			c.DBPath = fc.DBPath
+		case "simulator":
+			c.IsSimulator = fc.IsSimulator
+		case "test-rank":
+			c.TestRank = fc.TestRank
+		case "warmup":
+			c.WarmupFile = fc.WarmupFile
+		case "survey":
+			c.SurveyFile = fc.SurveyFile
+		case "laser-pair":
+			c.LaserPairFile = fc.LaserPairFile
+		case "achievements":
+			c.AchievementsFile = fc.AchievementsFile
+		case "record-dir":
+			c.RecordDir = fc.RecordDir
+		}
+	})
+	return c, fs.Args(), nil
+}
+
+func (c *ServerConfig) loadFile(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	f := struct {
+		Server *ServerConfig `toml:"server"`
+	}{c}
+	if _, err := toml.DecodeFile(path, &f); err != nil {
+		return fmt.Errorf("parse %v error:%v", path, err)
+	}
+	return nil
+}
+
+func (c *ServerConfig) loadEnv() error {
+	c.Host = envStr("CHALLENGER_HOST", c.Host)
+	c.PprofAddr = envStr("CHALLENGER_PPROF_ADDR", c.PprofAddr)
+	c.DBPath = envStr("CHALLENGER_DB_PATH", c.DBPath)
+	c.WarmupFile = envStr("CHALLENGER_WARMUP_FILE", c.WarmupFile)
+	c.SurveyFile = envStr("CHALLENGER_SURVEY_FILE", c.SurveyFile)
+	c.LaserPairFile = envStr("CHALLENGER_LASER_PAIR_FILE", c.LaserPairFile)
+	c.AchievementsFile = envStr("CHALLENGER_ACHIEVEMENTS_FILE", c.AchievementsFile)
+	c.RecordDir = envStr("CHALLENGER_RECORD_DIR", c.RecordDir)
+	ints := map[string]*int{
+		"CHALLENGER_HTTP_PORT": &c.HttpPort,
+		"CHALLENGER_TCP_PORT":  &c.TcpPort,
+		"CHALLENGER_UDP_PORT":  &c.UdpPort,
+	}
+	for k, p := range ints {
+		if v := os.Getenv(k); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %v:%v", k, v)
+			}
+			*p = i
+		}
+	}
+	bools := map[string]*bool{
+		"CHALLENGER_SIMULATOR": &c.IsSimulator,
+		"CHALLENGER_TEST_RANK": &c.TestRank,
+	}
+	for k, p := range bools {
+		if v := os.Getenv(k); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %v:%v", k, v)
+			}
+			*p = b
+		}
+	}
+	return nil
+}
+
+func envStr(key string, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
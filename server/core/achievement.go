@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"log"
+	"strconv"
+	"strings"
+)
+
+var _ = log.Printf
+
+// AchievementCondition compares one stat of a player with Value
+type AchievementCondition struct {
+	Stat  string  `toml:"stat"`
+	Op    string  `toml:"op"`
+	Value float64 `toml:"value"`
+}
+
+// Achievement is won by every player of a finished match who meets all of
+// its conditions. Modes limits it to some modes, empty means every mode.
+type Achievement struct {
+	ID          string                 `toml:"id"`
+	Name        string                 `toml:"name"`
+	Description string                 `toml:"description"`
+	Modes       []string               `toml:"modes"`
+	Conditions  []AchievementCondition `toml:"conditions"`
+}
+
+// Achievements is achievements.toml
+type Achievements struct {
+	Rules []Achievement `toml:"achievements"`
+}
+
+// stats a condition can use, the player's own values and the values of the
+// match, in versus matches gold, rampageCount and win are the player's side's
+var achievementStats = map[string]func(md *MatchData, pd *PlayerData) float64{
+	"gold":     func(md *MatchData, pd *PlayerData) float64 { return float64(pd.Gold) },
+	"lostGold": func(md *MatchData, pd *PlayerData) float64 { return float64(pd.LostGold) },
+	"energy":   func(md *MatchData, pd *PlayerData) float64 { return pd.Energy },
+	"combo":    func(md *MatchData, pd *PlayerData) float64 { return float64(pd.Combo) },
+	"maxCombo": func(md *MatchData, pd *PlayerData) float64 { return float64(pd.MaxCombo) },
+	"hitCount": func(md *MatchData, pd *PlayerData) float64 { return float64(pd.HitCount) },
+	"powerUps": func(md *MatchData, pd *PlayerData) float64 { return float64(pd.PowerUps) },
+	"buttons": func(md *MatchData, pd *PlayerData) float64 {
+		n := 0
+		for i, s := range strings.Split(pd.LevelData, ",") {
+			if c, err := strconv.Atoi(s); err == nil && i > 0 {
+				n += c
+			}
+		}
+		return float64(n)
+	},
+	"elasped":  func(md *MatchData, pd *PlayerData) float64 { return md.Elasped },
+	"teamSize": func(md *MatchData, pd *PlayerData) float64 { return float64(len(md.Member)) },
+	"teamGold": func(md *MatchData, pd *PlayerData) float64 {
+		if sd := md.side(pd.Side); sd != nil {
+			return float64(sd.Gold)
+		}
+		return float64(md.Gold)
+	},
+	"rampageCount": func(md *MatchData, pd *PlayerData) float64 {
+		if sd := md.side(pd.Side); sd != nil {
+			return float64(sd.RampageCount)
+		}
+		return float64(md.RampageCount)
+	},
+	"win": func(md *MatchData, pd *PlayerData) float64 {
+		if sd := md.side(pd.Side); sd != nil && sd.Win {
+			return 1
+		}
+		return 0
+	},
+}
+
+var achievementOps = map[string]func(a float64, b float64) bool{
+	"==": func(a float64, b float64) bool { return a == b },
+	"!=": func(a float64, b float64) bool { return a != b },
+	">":  func(a float64, b float64) bool { return a > b },
+	">=": func(a float64, b float64) bool { return a >= b },
+	"<":  func(a float64, b float64) bool { return a < b },
+	"<=": func(a float64, b float64) bool { return a <= b },
+}
+
+func LoadAchievements(path string) (*Achievements, error) {
+	var a Achievements
+	if _, err := toml.DecodeFile(path, &a); err != nil {
+		return nil, fmt.Errorf("parse achievements error:%v", err.Error())
+	}
+	var errs ValidationErrors
+	errs.merge(path+":", a.Validate())
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return &a, nil
+}
+
+func (a *Achievements) Validate() error {
+	var errs ValidationErrors
+	ids := make(map[string]bool)
+	for i, rule := range a.Rules {
+		field := fmt.Sprintf("achievements[%d]", i)
+		if rule.ID == "" {
+			errs.add(field+".id", "must not be empty")
+		} else if ids[rule.ID] {
+			errs.add(field+".id", "%v is used twice", rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Name == "" {
+			errs.add(field+".name", "must not be empty")
+		}
+		for _, mode := range rule.Modes {
+			if GetGameMode(mode) == nil {
+				errs.add(field+".modes", "unknown mode %v", mode)
+			}
+		}
+		if len(rule.Conditions) == 0 {
+			errs.add(field+".conditions", "must have at least one condition")
+		}
+		for j, c := range rule.Conditions {
+			cf := fmt.Sprintf("%v.conditions[%d]", field, j)
+			if _, ok := achievementStats[c.Stat]; !ok {
+				errs.add(cf+".stat", "unknown stat %v", c.Stat)
+			}
+			if _, ok := achievementOps[c.Op]; !ok {
+				errs.add(cf+".op", "unknown op %v", c.Op)
+			}
+		}
+	}
+	return errs.err()
+}
+
+func (rule *Achievement) matches(md *MatchData, pd *PlayerData) bool {
+	if len(rule.Modes) > 0 {
+		found := false
+		for _, mode := range rule.Modes {
+			found = found || mode == md.Mode
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, c := range rule.Conditions {
+		stat, op := achievementStats[c.Stat], achievementOps[c.Op]
+		if stat == nil || op == nil || !op(stat(md, pd), c.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate gives every member of a finished match the achievements they won
+func (a *Achievements) Evaluate(md *MatchData) {
+	for i := range md.Member {
+		pd := &md.Member[i]
+		pd.Achievements = make([]AchievementData, 0)
+		if a == nil {
+			continue
+		}
+		for _, rule := range a.Rules {
+			if rule.matches(md, pd) {
+				ad := AchievementData{}
+				ad.Key = rule.ID
+				ad.Name = rule.Name
+				ad.Description = rule.Description
+				pd.Achievements = append(pd.Achievements, ad)
+			}
+		}
+	}
+}
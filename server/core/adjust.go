@@ -0,0 +1,252 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+var _ = log.Printf
+
+// adjustRequest is an adjustMatch command from the admin. Delta is added to
+// Field, fields of a running match are gold, totalTime and lostGold, fields of
+// a saved match are gold, elasped and lostGold. Like a touch punish, a change
+// of lostGold takes the same amount from the gold of the team or side.
+type adjustRequest struct {
+	MatchID      uint
+	Field        string
+	Delta        float64
+	ControllerID string
+	Side         int
+	Reason       string
+}
+
+func parseAdjustRequest(msg *InboxMessage) (*adjustRequest, error) {
+	r := adjustRequest{}
+	mid, _ := msg.Get("matchID").(float64)
+	r.MatchID = uint(mid)
+	r.Field = msg.GetStr("field")
+	delta, ok := msg.Get("delta").(float64)
+	if !ok || delta == 0 {
+		return nil, fmt.Errorf("调整值无效")
+	}
+	r.Delta = delta
+	r.ControllerID = msg.GetStr("cid")
+	side, _ := msg.Get("side").(float64)
+	r.Side = int(side)
+	r.Reason = strings.TrimSpace(msg.GetStr("reason"))
+	if r.Reason == "" {
+		return nil, fmt.Errorf("必须填写调整原因")
+	}
+	return &r, nil
+}
+
+func (r *adjustRequest) log(old float64, new float64, live bool) AdjustData {
+	a := AdjustData{}
+	a.Field = r.Field
+	a.ControllerID = r.ControllerID
+	a.Side = r.Side
+	a.Old = old
+	a.New = new
+	a.Reason = r.Reason
+	a.Live = live
+	return a
+}
+
+// adjustMatch sends the command to the running match or corrects the saved
+// one, the result goes to every admin as matchAdjusted
+func (s *Srv) adjustMatch(msg *InboxMessage) {
+	r, err := parseAdjustRequest(msg)
+	if err != nil {
+		s.sendToOne(NewErrorInboxMessage(err.Error()), *msg.Address)
+		return
+	}
+	if match := s.mDict[r.MatchID]; match != nil {
+		match.OnMatchCmdArrived(msg)
+		return
+	}
+	md := s.db.getMatchData(r.MatchID)
+	if md == nil {
+		s.sendToOne(NewErrorInboxMessage("比赛不存在"), *msg.Address)
+		return
+	}
+	opt, err := s.GetOptions().WithProfile(md.Profile)
+	if err != nil {
+		s.sendToOne(NewErrorInboxMessage(err.Error()), *msg.Address)
+		return
+	}
+	a, err := adjustMatchData(opt, md, r)
+	if err != nil {
+		s.sendToOne(NewErrorInboxMessage(err.Error()), *msg.Address)
+		return
+	}
+	md.Adjustments = append(md.Adjustments, *a)
+	s.db.deleteAchievements(md)
+	s.getAchievements().Evaluate(md)
+	s.db.saveMatchData(md)
+	log.Printf("match %v adjusted:%+v\n", md.ID, *a)
+	d := map[string]interface{}{
+		"matchID":    md.ID,
+		"adjustment": a,
+		"matchData":  md,
+		"note":       regradeNote,
+	}
+	s.sendMsgs("matchAdjusted", d, InboxAddressTypeAdminDevice)
+}
+
+// adjust applies r to the running match, grades follow when it is dumped
+func (m *Match) adjust(msg *InboxMessage) {
+	r, err := parseAdjustRequest(msg)
+	if err == nil {
+		var a *AdjustData
+		if a, err = m.applyAdjust(r); err == nil {
+			m.matchData.Adjustments = append(m.matchData.Adjustments, *a)
+			log.Printf("match %v adjusted:%+v\n", m.ID, *a)
+			m.srv.sendMsgs("matchAdjusted", map[string]interface{}{"matchID": m.ID, "adjustment": a}, InboxAddressTypeAdminDevice)
+			return
+		}
+	}
+	m.srv.sends(NewErrorInboxMessage(err.Error()), InboxAddressTypeAdminDevice)
+}
+
+func (m *Match) applyAdjust(r *adjustRequest) (*AdjustData, error) {
+	var a AdjustData
+	switch r.Field {
+	case "gold":
+		delta := int(r.Delta)
+		if len(m.Sides) > 0 {
+			if r.Side < 1 || r.Side > len(m.Sides) {
+				return nil, fmt.Errorf("对战模式需要指定调整哪一方")
+			}
+			side := m.Sides[r.Side-1]
+			a = r.log(float64(side.Gold), float64(side.Gold+delta), true)
+			side.Gold += delta
+			m.Gold = m.sidesGold()
+		} else {
+			a = r.log(float64(m.Gold), float64(m.Gold+delta), true)
+			m.Gold += delta
+		}
+		m.adjustedGold += delta
+	case "totalTime":
+		t := math.Max(m.TotalTime+r.Delta, 0)
+		a = r.log(m.TotalTime, t, true)
+		m.TotalTime = t
+	case "lostGold":
+		player := m.getPlayer(r.ControllerID)
+		if player == nil {
+			return nil, fmt.Errorf("玩家不存在")
+		}
+		delta := int(r.Delta)
+		a = r.log(float64(player.LostGold), float64(player.LostGold+delta), true)
+		player.LostGold += delta
+		if len(m.Sides) > 0 {
+			m.Sides[player.Side-1].Gold -= delta
+			m.Gold = m.sidesGold()
+		} else {
+			m.Gold -= delta
+		}
+	default:
+		return nil, fmt.Errorf("无法调整%v", r.Field)
+	}
+	return &a, nil
+}
+
+// adjustMatchData applies r to a saved match and grades it again
+func adjustMatchData(opt *MatchOptions, md *MatchData, r *adjustRequest) (*AdjustData, error) {
+	var a AdjustData
+	switch r.Field {
+	case "gold":
+		delta := int(r.Delta)
+		if len(md.Sides) > 0 {
+			var side *SideData
+			for i := range md.Sides {
+				if md.Sides[i].Side == r.Side {
+					side = &md.Sides[i]
+				}
+			}
+			if side == nil {
+				return nil, fmt.Errorf("对战模式需要指定调整哪一方")
+			}
+			a = r.log(float64(side.Gold), float64(side.Gold+delta), false)
+			side.Gold += delta
+		} else {
+			a = r.log(float64(md.Gold), float64(md.Gold+delta), false)
+		}
+		md.Gold += delta
+	case "elasped":
+		t := math.Max(md.Elasped+r.Delta, 0)
+		a = r.log(md.Elasped, t, false)
+		md.Elasped = t
+	case "lostGold":
+		var player *PlayerData
+		for i := range md.Member {
+			if md.Member[i].ControllerID == r.ControllerID {
+				player = &md.Member[i]
+			}
+		}
+		if player == nil {
+			return nil, fmt.Errorf("玩家不存在")
+		}
+		delta := int(r.Delta)
+		a = r.log(float64(player.LostGold), float64(player.LostGold+delta), false)
+		player.LostGold += delta
+		md.Gold -= delta
+		for i := range md.Sides {
+			if md.Sides[i].Side == player.Side {
+				md.Sides[i].Gold -= delta
+			}
+		}
+	default:
+		return nil, fmt.Errorf("无法调整%v", r.Field)
+	}
+	if err := regradeMatchData(opt, md); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// the options a match was played with are not saved, a saved match is graded
+// again with the current ones of its profile, hot reloads included
+const regradeNote = "已按当前配置重新评定等级和成就, 配置在比赛后修改过时结果可能与比赛时的标准不同"
+
+// regradeMatchData grades a saved match again with the grading of its mode,
+// the mode only sees the parts of the match that are saved. opt is the
+// current options of the profile of the match, see regradeNote.
+func regradeMatchData(opt *MatchOptions, md *MatchData) error {
+	mode := GetGameMode(md.Mode)
+	if mode == nil {
+		return fmt.Errorf("未知的模式%v", md.Mode)
+	}
+	m := Match{}
+	m.opt = opt
+	m.mode = mode
+	m.Mode = md.Mode
+	m.Elasped = md.Elasped
+	m.Gold = md.Gold
+	m.Member = make([]*Player, len(md.Member))
+	for i, pd := range md.Member {
+		p := NewPlayer(pd.ControllerID, false)
+		p.Gold = pd.Gold
+		p.LostGold = pd.LostGold
+		p.Side = pd.Side
+		m.Member[i] = p
+	}
+	if len(md.Sides) > 0 {
+		m.Sides = make([]*Side, len(md.Sides))
+		for i, sd := range md.Sides {
+			m.Sides[i] = &Side{Index: sd.Side, Gold: sd.Gold, Size: len(m.sideMembers(sd.Side))}
+		}
+	}
+	md.Grade = mode.TeamGrade(&m)
+	for i := range md.Member {
+		md.Member[i].Grade = mode.PersonGrade(&m, m.Member[i])
+	}
+	winner := m.winnerSide()
+	for i := range md.Sides {
+		sd := &md.Sides[i]
+		sd.Win = winner == sd.Side
+		sd.Grade = opt.calcGrade(sd.Gold, m.Sides[i].Size, opt.GoldTeamRank)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+var _ = log.Printf
+
+// Arena is the room with its arduinos, lasers and wearables. Only one match
+// at a time may drive it, the match holding it gets every message of the
+// arena's devices. The main loop of the Srv changes it, other goroutines
+// read it through status.
+type Arena struct {
+	Name    string `json:"name"`    // SrvConfig.Arena, may be empty
+	MatchID uint   `json:"matchID"` // 0 when no match holds the arena
+	// takeover is started as soon as the match holding the arena has ended
+	takeover *pendingMatch
+	lock     *sync.RWMutex
+}
+
+func NewArena(name string) *Arena {
+	return &Arena{Name: name, lock: new(sync.RWMutex)}
+}
+
+// status is a copy of the arena safe to marshal on any goroutine
+func (a *Arena) status() Arena {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return Arena{Name: a.Name, MatchID: a.MatchID}
+}
+
+func (a *Arena) busy() bool {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.MatchID > 0
+}
+
+func (a *Arena) acquire(mid uint) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.MatchID = mid
+}
+
+// release frees the arena if mid holds it and returns the takeover waiting
+// for it
+func (a *Arena) release(mid uint) *pendingMatch {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.MatchID != mid {
+		return nil
+	}
+	a.MatchID = 0
+	p := a.takeover
+	a.takeover = nil
+	return p
+}
+
+func (a *Arena) setTakeover(p *pendingMatch) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.takeover = p
+}
+
+func (a *Arena) busyError() string {
+	return fmt.Sprintf("比赛%v正在进行, 场地被占用", a.status().MatchID)
+}
+
+// currentMatch is the match holding the arena, nil when it is free
+func (s *Srv) currentMatch() *Match {
+	if !s.arena.busy() {
+		return nil
+	}
+	return s.mDict[s.arena.status().MatchID]
+}
+
+// takeOverArena stops the match holding the arena and starts p once it has
+// ended, a later takeover replaces one that is still waiting
+func (s *Srv) takeOverArena(p *pendingMatch) {
+	m := s.currentMatch()
+	if m == nil {
+		s.arena.release(s.arena.status().MatchID)
+		s.startPendingMatch(p)
+		return
+	}
+	log.Printf("arena taken over from match %v by team %v\n", m.ID, p.teamID)
+	s.arena.setTakeover(p)
+	d := map[string]interface{}{
+		"matchID": m.ID,
+		"teamID":  p.teamID,
+	}
+	s.sendMsgs("arenaTakeover", d, InboxAddressTypeAdminDevice)
+	stop := NewInboxMessage()
+	stop.SetCmd("stopMatch")
+	m.OnMatchCmdArrived(stop)
+}
+
+func (s *Srv) startPendingMatch(p *pendingMatch) {
+	if p.teamID != "" {
+		s.queue.TeamStart(p.teamID)
+	}
+	s.startNewMatch(p.ids, p.mode, p.profile, p.teamID)
+}
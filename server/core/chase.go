@@ -0,0 +1,259 @@
+package core
+
+import (
+	"log"
+)
+
+var _ = log.Printf
+
+const (
+	defaultChaseStrategy = "direct"
+	// a patrolling laser starts to chase its player this many tiles away
+	patrolChaseDistance = 2
+	// distances of unreachable tiles
+	chaseUnreachable = 10000
+)
+
+// ChaseStrategy picks the tile a laser moves to next. Each laser gets its
+// own instance so a strategy can remember where it has been. Tiles are the
+// indexes of MatchOptions.TileAdjacency.
+type ChaseStrategy interface {
+	Next(c *ChaseContext) int
+}
+
+// chaseDistance is implemented by strategies that head for a tile, Distance
+// is the number of steps from tile to where the last Next was heading. A
+// laser halfway between two tiles goes on to the one that is not farther.
+type chaseDistance interface {
+	Distance(c *ChaseContext, tile int) int
+}
+
+// ChaseContext is what a laser knows when it picks its next tile
+type ChaseContext struct {
+	Match  *Match
+	Laser  LaserInterface
+	From   int
+	Target int // the tile of the laser's player
+}
+
+var chaseStrategies = make(map[string]func() ChaseStrategy)
+
+// RegisterChaseStrategy makes a strategy available to laserStrategy options
+func RegisterChaseStrategy(name string, f func() ChaseStrategy) {
+	if _, ok := chaseStrategies[name]; ok {
+		log.Printf("warning:chase strategy %v registered twice\n", name)
+	}
+	chaseStrategies[name] = f
+}
+
+func newChaseStrategy(name string) ChaseStrategy {
+	if f, ok := chaseStrategies[name]; ok {
+		return f()
+	}
+	return chaseStrategies[defaultChaseStrategy]()
+}
+
+func init() {
+	RegisterChaseStrategy("direct", func() ChaseStrategy { return &directChase{} })
+	RegisterChaseStrategy("intercept", func() ChaseStrategy { return &interceptChase{last: -1, target: -1} })
+	RegisterChaseStrategy("patrol", func() ChaseStrategy { return &patrolChase{} })
+	RegisterChaseStrategy("coordinated", func() ChaseStrategy { return &coordinatedChase{} })
+}
+
+// chaseStrategyName is the strategy lasers of mode use, laserStrategies
+// picks one per mode and laserStrategy is used for the other modes
+func (m *MatchOptions) chaseStrategyName(mode string) string {
+	if name, ok := m.LaserStrategies[mode]; ok {
+		return name
+	}
+	if m.LaserStrategy != "" {
+		return m.LaserStrategy
+	}
+	return defaultChaseStrategy
+}
+
+// distanceMap keeps the number of steps from every tile to dest, it is only
+// filled again when dest changes
+type distanceMap struct {
+	dest int
+	dist map[int]int
+}
+
+func (d *distanceMap) fill(opt *MatchOptions, dest int) map[int]int {
+	if d.dist != nil && d.dest == dest {
+		return d.dist
+	}
+	d.dest = dest
+	d.dist = make(map[int]int)
+	for i := 0; i < opt.ArenaWidth*opt.ArenaHeight; i++ {
+		d.dist[i] = chaseUnreachable
+	}
+	var fill func(x int, v int)
+	fill = func(x int, v int) {
+		d.dist[x] = v
+		for _, i := range opt.TileAdjacency[x] {
+			if d.dist[i] > v+1 {
+				fill(i, v+1)
+			}
+		}
+	}
+	fill(dest, 0)
+	return d.dist
+}
+
+// stepToward is the first neighbour of from that is closer to the
+// destination of dist, from itself if there is none
+func stepToward(opt *MatchOptions, dist map[int]int, from int) int {
+	next, min := from, dist[from]
+	for _, i := range opt.TileAdjacency[from] {
+		if dist[i] < min {
+			min = dist[i]
+			next = i
+		}
+	}
+	return next
+}
+
+// directChase steps greedily toward the player's current tile
+type directChase struct {
+	dm distanceMap
+}
+
+func (s *directChase) Next(c *ChaseContext) int {
+	opt := c.Match.opt
+	return stepToward(opt, s.dm.fill(opt, c.Target), c.From)
+}
+
+func (s *directChase) Distance(c *ChaseContext, tile int) int {
+	return s.dm.fill(c.Match.opt, c.Target)[tile]
+}
+
+// interceptChase heads for the tile the player reaches next if they keep
+// going the way they came, the player's tile when that is not possible
+type interceptChase struct {
+	dm       distanceMap
+	toTarget distanceMap
+	last     int // the player's previous tile
+	target   int
+	dest     int // of the last Next
+}
+
+func (s *interceptChase) Next(c *ChaseContext) int {
+	opt := c.Match.opt
+	if c.Target != s.target {
+		s.last, s.target = s.target, c.Target
+	}
+	dest := c.Target
+	if s.last >= 0 && s.last != c.Target {
+		ahead := c.Target + (c.Target - s.last)
+		if opt.adjacent(c.Target, ahead) && opt.adjacent(s.last, c.Target) {
+			dest = ahead
+		}
+	}
+	// never run past the player to reach the predicted tile
+	if dest != c.Target && s.dm.fill(opt, dest)[c.From] > s.toTarget.fill(opt, c.Target)[c.From] {
+		dest = c.Target
+	}
+	s.dest = dest
+	return stepToward(opt, s.dm.fill(opt, dest), c.From)
+}
+
+func (s *interceptChase) Distance(c *ChaseContext, tile int) int {
+	return s.dm.fill(c.Match.opt, s.dest)[tile]
+}
+
+// patrolChase walks between the corners of the arena and only chases the
+// player once they come close, a roaming one never chases
+type patrolChase struct {
+	dm       distanceMap
+	chase    distanceMap
+	waypoint int
+	roam     bool
+	last     *distanceMap // the one the last Next stepped on
+}
+
+func (s *patrolChase) Next(c *ChaseContext) int {
+	opt := c.Match.opt
+	if !s.roam {
+		dist := s.chase.fill(opt, c.Target)
+		if dist[c.From] <= patrolChaseDistance {
+			s.last = &s.chase
+			return stepToward(opt, dist, c.From)
+		}
+	}
+	w, h := opt.ArenaWidth, opt.ArenaHeight
+	corners := []int{0, w - 1, w*h - 1, w * (h - 1)}
+	dest := corners[s.waypoint%len(corners)]
+	if c.From == dest {
+		s.waypoint += 1
+		dest = corners[s.waypoint%len(corners)]
+	}
+	s.last = &s.dm
+	return stepToward(opt, s.dm.fill(opt, dest), c.From)
+}
+
+func (s *patrolChase) Distance(c *ChaseContext, tile int) int {
+	if s.last == nil {
+		return chaseUnreachable
+	}
+	return s.last.dist[tile]
+}
+
+// coordinatedChase chases like directChase but takes another way when the
+// shortest one runs next to other lasers, so lasers of a team close in
+// from different sides instead of following each other
+type coordinatedChase struct {
+	dm distanceMap
+}
+
+func (s *coordinatedChase) Next(c *ChaseContext) int {
+	opt := c.Match.opt
+	dist := s.dm.fill(opt, c.Target)
+	others := make(map[int]bool)
+	for _, l := range c.Match.Lasers {
+		if l != c.Laser {
+			others[l.Tile()] = true
+		}
+	}
+	crowd := func(t int) int {
+		n := 0
+		if others[t] {
+			n += 1
+		}
+		for _, i := range opt.TileAdjacency[t] {
+			if others[i] {
+				n += 1
+			}
+		}
+		return n
+	}
+	next, best := c.From, -1
+	for _, i := range opt.TileAdjacency[c.From] {
+		if dist[i] < dist[c.From] && (best < 0 || crowd(i) < best) {
+			next, best = i, crowd(i)
+		}
+	}
+	if best > 0 {
+		// every shortest step is crowded, a free sideways step opens
+		// another corridor
+		for _, i := range opt.TileAdjacency[c.From] {
+			if dist[i] == dist[c.From] && crowd(i) == 0 {
+				return i
+			}
+		}
+	}
+	return next
+}
+
+func (s *coordinatedChase) Distance(c *ChaseContext, tile int) int {
+	return s.dm.fill(c.Match.opt, c.Target)[tile]
+}
+
+func (m *MatchOptions) adjacent(a int, b int) bool {
+	for _, i := range m.TileAdjacency[a] {
+		if i == b {
+			return true
+		}
+	}
+	return false
+}
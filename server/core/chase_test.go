@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// a laser halfway along one of two shortest steps goes on instead of
+// turning back to the step directChase would have picked
+func TestLaserFinishesEquallyShortMove(t *testing.T) {
+	s := newTestSrv(t, NewManualClock(time.Unix(1000, 0)))
+	md := MatchData{Seed: 42}
+	m := NewMatch(s, s.GetOptions(), []string{"1"}, &md, "g", "", true)
+	opt := m.opt
+	var dm distanceMap
+	for target := range opt.TileAdjacency {
+		dist := dm.fill(opt, target)
+		for from := range opt.TileAdjacency {
+			picked := stepToward(opt, dist, from)
+			for _, other := range opt.TileAdjacency[from] {
+				if other == picked || dist[other] != dist[picked] || dist[other] >= dist[from] {
+					continue
+				}
+				player := m.Member[0]
+				player.tilePos = opt.IntToTile(opt.Conv(target))
+				l := &Laser{player: player, chase: &directChase{}, match: m, p: opt.Conv(from), p2: opt.Conv(other)}
+				if got := l.findPath(); got != opt.Conv(other) {
+					t.Fatalf("laser from %v to %v chasing %v turned to %v", from, other, target, opt.Conv(got))
+				}
+				return
+			}
+		}
+	}
+	t.Skip("no tile with two shortest steps in the arena")
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of a match
+type Clock interface {
+	Now() time.Time
+	// Tick fires every d while the match runs
+	Tick(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Tick(d time.Duration) <-chan time.Time {
+	return time.Tick(d)
+}
+
+// ManualClock only moves when Advance is called. Its Tick never fires, a
+// match using it is driven by calling Advance and Match.Step in turn.
+type ManualClock struct {
+	now  time.Time
+	lock *sync.Mutex
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	c := ManualClock{}
+	c.now = start
+	c.lock = new(sync.Mutex)
+	return &c
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) Tick(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
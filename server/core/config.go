@@ -0,0 +1,96 @@
+package core
+
+import (
+	"os"
+)
+
+// ConfigPaths locates the config files of an arena
+type ConfigPaths struct {
+	Cfg       string
+	Warmup    string
+	Survey    string
+	LaserPair string
+	// Achievements is optional, without the file no achievement is won
+	Achievements string
+}
+
+func DefaultConfigPaths() ConfigPaths {
+	return ConfigPaths{
+		Cfg:          "cfg.toml",
+		Warmup:       "warmup.toml",
+		Survey:       "survey.toml",
+		LaserPair:    "laser.json",
+		Achievements: "achievements.toml",
+	}
+}
+
+// SrvConfig is everything a Srv needs, it can be loaded from files with
+// LoadSrvConfig or built in code when core is used as a library.
+// Paths are used when the config is reloaded and when laser pairs are saved.
+type SrvConfig struct {
+	Options *MatchOptions
+	Survey  *Survey
+	// Achievements are given at the end of every match, nil gives none
+	Achievements *Achievements
+	LaserPair    *LaserPair
+	Paths        ConfigPaths
+	IsSimulator  bool
+	// Clock drives matches, nil uses the wall clock
+	Clock Clock
+	// RecordDir keeps a recording of every match, empty disables recording
+	RecordDir string
+	// Arena names the arena in a Hub, clients pick it with ARENA on init
+	Arena string
+}
+
+// LoadSrvConfig reads and validates every config file in paths
+func LoadSrvConfig(paths ConfigPaths, isSimulator bool) (*SrvConfig, error) {
+	o, sv, av, err := loadReloadable(paths)
+	if err != nil {
+		return nil, err
+	}
+	lp, err := LoadLaserPair(paths.LaserPair)
+	if err != nil {
+		return nil, err
+	}
+	return &SrvConfig{o, sv, av, lp, paths, isSimulator, nil, "", ""}, nil
+}
+
+// ValidateConfig reports every problem of the config files in paths
+func ValidateConfig(paths ConfigPaths) error {
+	var errs ValidationErrors
+	_, err := LoadMatchOptions(paths.Cfg, paths.Warmup)
+	errs.merge("", err)
+	_, err = LoadSurvey(paths.Survey)
+	errs.merge("", err)
+	_, err = LoadLaserPair(paths.LaserPair)
+	errs.merge("", err)
+	_, err = loadAchievements(paths.Achievements)
+	errs.merge("", err)
+	return errs.err()
+}
+
+func loadReloadable(paths ConfigPaths) (*MatchOptions, *Survey, *Achievements, error) {
+	var errs ValidationErrors
+	o, err := LoadMatchOptions(paths.Cfg, paths.Warmup)
+	errs.merge("", err)
+	sv, err := LoadSurvey(paths.Survey)
+	errs.merge("", err)
+	av, err := loadAchievements(paths.Achievements)
+	errs.merge("", err)
+	if len(errs) > 0 {
+		return nil, nil, nil, errs
+	}
+	return o, sv, av, nil
+}
+
+// loadAchievements is LoadAchievements for an optional file
+func loadAchievements(path string) (*Achievements, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return LoadAchievements(path)
+}
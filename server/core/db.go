@@ -35,6 +35,11 @@ type PlayerData struct {
 	ControllerID string    `json:"cid"`
 	QuestionInfo string    `json:"questionInfo"`
 	Answered     int       `json:"answered"`
+	Side         int       `json:"side"`
+	PowerUps     int       `json:"powerUps"`
+	MaxCombo     int       `json:"maxCombo"`
+
+	Achievements []AchievementData `gorm:"ForeignKey:PlayerID" json:"achievements"`
 }
 
 func (PlayerData) TableName() string {
@@ -45,6 +50,8 @@ type MatchData struct {
 	ID           uint            `json:"id"`
 	CreatedAt    time.Time       `json:"createdAt"`
 	Mode         string          `json:"mode"`
+	Arena        string          `gorm:"index;default:''" json:"arena"`
+	Profile      string          `gorm:"index" json:"profile"`
 	Elasped      float64         `json:"elasped"`
 	Gold         int             `json:"gold"`
 	Member       []PlayerData    `gorm:"ForeignKey:MatchID" json:"member"`
@@ -53,12 +60,123 @@ type MatchData struct {
 	TeamID       string          `json:"teamID"`
 	ExternalID   string          `gorm:"index" json:"eid"`
 	Grade        string          `json:"grade"`
+	Seed         int64           `json:"seed"`
+	Sides        []SideData      `gorm:"ForeignKey:MatchID" json:"sides"`
+	Pauses       []PauseData     `gorm:"ForeignKey:MatchID" json:"pauses"`
+	Dropouts     []DropoutData   `gorm:"ForeignKey:MatchID" json:"dropouts"`
+	Adjustments  []AdjustData    `gorm:"ForeignKey:MatchID" json:"adjustments"`
+	Timeline     *TimelineData   `gorm:"ForeignKey:MatchID" json:"-"`
 }
 
 func (MatchData) TableName() string {
 	return "matches"
 }
 
+// SideData is the result of one side of a versus match
+type SideData struct {
+	ID           uint   `json:"id"`
+	MatchID      int    `json:"-"`
+	Side         int    `json:"side"`
+	Gold         int    `json:"gold"`
+	RampageCount int    `json:"rampageCount"`
+	Grade        string `json:"grade"`
+	Win          bool   `json:"win"`
+}
+
+func (SideData) TableName() string {
+	return "sides"
+}
+
+// side is the result of side, nil when the match is not a versus match
+func (md *MatchData) side(side int) *SideData {
+	for i := range md.Sides {
+		if md.Sides[i].Side == side {
+			return &md.Sides[i]
+		}
+	}
+	return nil
+}
+
+// AchievementData is an achievement a player won in a match, Key is the id
+// in achievements.toml and the name is kept as it was when it was won
+type AchievementData struct {
+	ID          uint   `json:"id"`
+	PlayerID    int    `json:"-"`
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (AchievementData) TableName() string {
+	return "achievements"
+}
+
+// TimelineData is the encoded samples of a match, see encodeTimeline.
+// Players is the number of players in each sample.
+type TimelineData struct {
+	ID       uint    `json:"id"`
+	MatchID  int     `json:"-"`
+	Interval float64 `json:"interval"`
+	Count    int     `json:"count"`
+	Players  int     `json:"players"`
+	Stages   string  `json:"stages"`
+	Data     []byte  `json:"-"`
+}
+
+func (TimelineData) TableName() string {
+	return "timelines"
+}
+
+// PauseData is one period a match was paused by the staff or a wearable
+// dropout, At is the elasped time of the match when it was paused
+type PauseData struct {
+	ID        uint      `json:"id"`
+	MatchID   int       `json:"-"`
+	Stage     string    `json:"stage"`
+	At        float64   `json:"at"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  float64   `json:"duration"`
+}
+
+func (PauseData) TableName() string {
+	return "pauses"
+}
+
+// DropoutData is one period the wearable of a player was offline during a
+// match, Policy is empty when it came back within dropoutGrace
+type DropoutData struct {
+	ID           uint      `json:"id"`
+	MatchID      int       `json:"-"`
+	ControllerID string    `json:"cid"`
+	At           float64   `json:"at"`
+	StartedAt    time.Time `json:"startedAt"`
+	Duration     float64   `json:"duration"`
+	Policy       string    `json:"policy"`
+}
+
+func (DropoutData) TableName() string {
+	return "dropouts"
+}
+
+// AdjustData is a change the staff made to a match, live or after it was
+// saved. ControllerID is set for player fields, Side for a side's gold.
+type AdjustData struct {
+	ID           uint      `json:"id"`
+	MatchID      int       `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Field        string    `json:"field"`
+	ControllerID string    `json:"cid"`
+	Side         int       `json:"side"`
+	Old          float64   `json:"old"`
+	New          float64   `json:"new"`
+	Reason       string    `json:"reason"`
+	Live         bool      `json:"live"`
+}
+
+func (AdjustData) TableName() string {
+	return "adjustments"
+}
+
 type DB struct {
 	conn *gorm.DB
 }
@@ -69,7 +187,7 @@ func NewDb() *DB {
 
 func (db *DB) connect(path string) error {
 	conn, err := gorm.Open("sqlite3", path)
-	conn.AutoMigrate(&MatchData{}, &PlayerData{})
+	conn.AutoMigrate(&MatchData{}, &PlayerData{}, &SideData{}, &PauseData{}, &DropoutData{}, &AdjustData{}, &AchievementData{}, &TimelineData{})
 	if err != nil {
 		return err
 	}
@@ -77,6 +195,11 @@ func (db *DB) connect(path string) error {
 	return nil
 }
 
+// details loads a match together with every table linked to it
+func (db *DB) details() *gorm.DB {
+	return db.conn.Preload("Member").Preload("Member.Achievements").Preload("Sides").Preload("Pauses").Preload("Dropouts").Preload("Adjustments")
+}
+
 func (db *DB) newMatch() *MatchData {
 	var m = MatchData{}
 	db.conn.Create(&m)
@@ -91,15 +214,39 @@ func (db *DB) saveOrDelMatchData(m *MatchData) {
 	}
 }
 
-func (db *DB) getHistory(count int) []MatchData {
+func (db *DB) deleteMatchData(m *MatchData) {
+	db.conn.Delete(m)
+}
+
+func (db *DB) getHistory(count int, arena string) []MatchData {
+	var matches []MatchData
+	db.details().Order("id desc").Where("elasped > 0 and arena = ?", arena).Limit(count).Find(&matches)
+	return matches
+}
+
+func (db *DB) getProfileHistory(count int, arena string, profile string) []MatchData {
 	var matches []MatchData
-	db.conn.Order("id desc").Where("elasped > 0").Limit(count).Preload("Member").Find(&matches)
+	db.details().Order("id desc").Where("elasped > 0 and arena = ? and profile = ?", arena, profile).Limit(count).Find(&matches)
 	return matches
 }
 
+// getTimeline loads match mid with its members and samples, the timeline
+// is nil when it has none
+func (db *DB) getTimeline(mid uint) (*MatchData, *TimelineData) {
+	var match MatchData
+	if db.conn.Preload("Member").Where("id = ?", mid).First(&match).RecordNotFound() {
+		return nil, nil
+	}
+	var t TimelineData
+	if db.conn.Where("match_id = ?", mid).First(&t).RecordNotFound() {
+		return &match, nil
+	}
+	return &match, &t
+}
+
 func (db *DB) startAnswer(mid int, eid string) *MatchData {
 	var match MatchData
-	db.conn.Preload("Member").Where("id = ?", mid).First(&match)
+	db.details().Where("id = ?", mid).First(&match)
 	match.AnswerType = MatchAnswering
 	match.ExternalID = eid
 	db.conn.Save(&match)
@@ -131,8 +278,8 @@ func (db *DB) updatePlayerData(pid int, name string, uid string) *PlayerData {
 	return &player
 }
 
-func (db *DB) getAnsweringMatchData() *MatchData {
-	matches := db.getHistory(12)
+func (db *DB) getAnsweringMatchData(arena string) *MatchData {
+	matches := db.getHistory(12, arena)
 	for _, match := range matches {
 		if match.AnswerType == MatchAnswering {
 			return &match
@@ -148,3 +295,23 @@ func (db *DB) updateMatchData(mid int, eid string) *MatchData {
 	db.conn.Save(&match)
 	return &match
 }
+
+func (db *DB) getMatchData(mid uint) *MatchData {
+	var match MatchData
+	if db.details().Where("id = ?", mid).First(&match).RecordNotFound() {
+		return nil
+	}
+	return &match
+}
+
+func (db *DB) saveMatchData(m *MatchData) {
+	db.conn.Save(m)
+}
+
+// deleteAchievements removes the saved achievements of the members of m
+// before they are evaluated again, Save would keep the old rows
+func (db *DB) deleteAchievements(m *MatchData) {
+	for _, pd := range m.Member {
+		db.conn.Where("player_id = ?", pd.ID).Delete(AchievementData{})
+	}
+}
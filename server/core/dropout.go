@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"log"
+)
+
+var _ = log.Printf
+
+// what a match does once a wearable has been offline for dropoutGrace seconds,
+// until then the laser of the player waits where it is
+const (
+	DropoutPolicyPause    = "pause"    // pause the match until the staff resumes it
+	DropoutPolicyPatrol   = "patrol"   // the laser of the player patrols the arena
+	DropoutPolicyContinue = "continue" // the laser chases the last known tile
+)
+
+// a match paused by DropoutPolicyPause that staff resume while the wearable
+// is still offline goes on with this policy instead of freezing the laser
+const dropoutResumePolicy = DropoutPolicyPatrol
+
+// dropout is a wearable that went offline during warmup, ongoing or a pause
+// of them, the grace period only runs while the match does
+type dropout struct {
+	data    DropoutData
+	offline float64 // seconds of the match the wearable has been offline
+}
+
+func (m *Match) startDropout(player *Player) {
+	if !m.isWarmup() && !m.isOngoing() && m.Stage != stagePaused {
+		return
+	}
+	if _, ok := m.dropouts[player.ControllerID]; ok {
+		return
+	}
+	d := &dropout{}
+	d.data.ControllerID = player.ControllerID
+	d.data.At = m.Elasped
+	d.data.StartedAt = m.clock.Now()
+	m.dropouts[player.ControllerID] = d
+	log.Printf("wearable of player %v dropped out\n", player.ControllerID)
+	text := fmt.Sprintf("玩家%v的穿戴设备掉线, %v秒内未恢复将%v", player.ControllerID, m.opt.DropoutGrace, dropoutPolicyTitle(m.opt.DropoutPolicy))
+	m.srv.sends(NewErrorInboxMessage(text), InboxAddressTypeAdminDevice)
+}
+
+// dropoutTick applies the policy to the dropouts whose grace period is over
+func (m *Match) dropoutTick(player *Player, sec float64) {
+	d, ok := m.dropouts[player.ControllerID]
+	if !ok || d.data.Policy != "" {
+		return
+	}
+	d.offline += sec
+	if d.offline < m.opt.DropoutGrace {
+		return
+	}
+	d.data.Policy = m.opt.DropoutPolicy
+	log.Printf("wearable of player %v still offline, %v\n", player.ControllerID, d.data.Policy)
+	switch d.data.Policy {
+	case DropoutPolicyPause:
+		if m.Stage != stagePaused {
+			m.pause()
+		}
+	case DropoutPolicyPatrol, DropoutPolicyContinue:
+		player.dropoutPolicy = d.data.Policy
+	}
+}
+
+// endDropout saves the dropout of player when the wearable is back or the
+// match is over
+func (m *Match) endDropout(player *Player) {
+	player.dropoutPolicy = ""
+	d, ok := m.dropouts[player.ControllerID]
+	if !ok {
+		return
+	}
+	delete(m.dropouts, player.ControllerID)
+	d.data.Duration = m.clock.Now().Sub(d.data.StartedAt).Seconds()
+	m.matchData.Dropouts = append(m.matchData.Dropouts, d.data)
+	log.Printf("wearable of player %v back after %.1fs\n", player.ControllerID, d.data.Duration)
+}
+
+// resumeDropouts gives the players the match was paused for and whose
+// wearable is still offline dropoutResumePolicy
+func (m *Match) resumeDropouts() {
+	for _, player := range m.Member {
+		d, ok := m.dropouts[player.ControllerID]
+		if !ok || d.data.Policy != DropoutPolicyPause {
+			continue
+		}
+		player.dropoutPolicy = dropoutResumePolicy
+		log.Printf("wearable of player %v still offline after resume, %v\n", player.ControllerID, dropoutResumePolicy)
+		text := fmt.Sprintf("玩家%v的穿戴设备仍未恢复, 将%v", player.ControllerID, dropoutPolicyTitle(dropoutResumePolicy))
+		m.srv.sends(NewErrorInboxMessage(text), InboxAddressTypeAdminDevice)
+	}
+}
+
+func (m *Match) endDropouts() {
+	for _, player := range m.Member {
+		m.endDropout(player)
+	}
+}
+
+func dropoutPolicyTitle(policy string) string {
+	switch policy {
+	case DropoutPolicyPause:
+		return "暂停比赛"
+	case DropoutPolicyPatrol:
+		return "让激光巡逻"
+	}
+	return "继续比赛"
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"log"
+	"math"
+)
+
+var _ = log.Printf
+
+// StageEffects are the hardware effect codes a mode shows in each stage
+type StageEffects struct {
+	ButtonMode string // mode of btn_ctrl
+	Door       string // doors and wall leds in warmup and ongoing-low
+	LowLedBase int    // wall leds of ongoing-low-n show LowLedBase+n
+	HighLed    string // doors and wall leds in ongoing-high
+	FullLed    string // wall leds in ongoing-full
+}
+
+// GameMode holds the rules that differ between kinds of matches. A Match
+// keeps the parts every mode shares (warmup, buttons, lasers, rampage) and
+// calls its mode at fixed points, modes keep their state in the Match.
+type GameMode interface {
+	// Name is the code clients send, e.g. "g"
+	Name() string
+	// Title is shown in the admin mode list
+	Title() string
+	// OptionIndex selects the column of options given per mode, e.g. rampageTime
+	OptionIndex() int
+	Effects() StageEffects
+	// Init is called before warmup
+	Init(m *Match)
+	// Start is called when warmup is over
+	Start(m *Match)
+	// Tick is called every tick of an ongoing stage
+	Tick(m *Match, sec float64)
+	// ConsumeButton credits a pressed button of level 1-3
+	ConsumeButton(m *Match, p *Player, level int)
+	// TouchPunish is called when p touched a laser and was not invincible
+	TouchPunish(m *Match, p *Player)
+	// NextStage gets the stage picked by energy and returns the stage to go
+	// to, this is where a mode ends the match
+	NextStage(m *Match, s string) string
+	TeamGrade(m *Match) string
+	PersonGrade(m *Match, p *Player) string
+}
+
+type GameModeInfo struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+}
+
+var gameModes = make(map[string]GameMode)
+var gameModeNames = make([]string, 0)
+
+// RegisterGameMode makes a mode available to teamStart by its name
+func RegisterGameMode(mode GameMode) {
+	if _, ok := gameModes[mode.Name()]; ok {
+		log.Printf("warning:game mode %v registered twice\n", mode.Name())
+	} else {
+		gameModeNames = append(gameModeNames, mode.Name())
+	}
+	gameModes[mode.Name()] = mode
+}
+
+func GetGameMode(name string) GameMode {
+	return gameModes[name]
+}
+
+// GameModes lists registered modes in registration order
+func GameModes() []GameModeInfo {
+	li := make([]GameModeInfo, len(gameModeNames))
+	for i, name := range gameModeNames {
+		li[i] = GameModeInfo{name, gameModes[name].Title()}
+	}
+	return li
+}
+
+func init() {
+	RegisterGameMode(bountyMode{})
+	RegisterGameMode(survivalMode{})
+}
+
+// teamScoring credits every button to the team as a whole
+type teamScoring struct{}
+
+func (teamScoring) ConsumeButton(m *Match, p *Player, level int) {
+	m.scoreButton(p, level, m.RampageTime > 0, &m.Gold, &m.Energy)
+}
+
+// 赏金模式, collect as much gold as possible before time runs out
+type bountyMode struct {
+	teamScoring
+}
+
+func (bountyMode) Name() string {
+	return "g"
+}
+
+func (bountyMode) Title() string {
+	return "赏金"
+}
+
+func (bountyMode) OptionIndex() int {
+	return 0
+}
+
+func (bountyMode) Effects() StageEffects {
+	return StageEffects{ButtonMode: "1", Door: "5", LowLedBase: 5, HighLed: "9", FullLed: "19"}
+}
+
+func (bountyMode) Init(m *Match) {
+	m.TotalTime = m.opt.Mode1TotalTime
+}
+
+func (bountyMode) Start(m *Match) {
+}
+
+func (bountyMode) Tick(m *Match, sec float64) {
+	m.TotalTime = math.Max(m.TotalTime-sec, 0)
+}
+
+func (bountyMode) TouchPunish(m *Match, p *Player) {
+	punish := m.scoring.touchGold(m.modeIndex(), len(m.Member))
+	m.Gold = m.Gold - punish
+	p.LostGold += punish
+}
+
+func (bountyMode) NextStage(m *Match, s string) string {
+	if s != "ongoing-rampage" && m.TotalTime < m.opt.Mode1CountDown {
+		s = "ongoing-countdown"
+	}
+	if m.TotalTime <= 0 {
+		s = "after"
+	}
+	return s
+}
+
+func (bountyMode) TeamGrade(m *Match) string {
+	return m.opt.calcGrade(m.Gold, len(m.Member), m.opt.GoldTeamRank)
+}
+
+func (bountyMode) PersonGrade(m *Match, p *Player) string {
+	return m.opt.calcGrade(p.Gold-p.LostGold, len(m.Member), m.opt.GoldRank)
+}
+
+// 生存模式, gold drops over time and the match ends when it runs out
+type survivalMode struct {
+	teamScoring
+}
+
+func (survivalMode) Name() string {
+	return "s"
+}
+
+func (survivalMode) Title() string {
+	return "生存"
+}
+
+func (survivalMode) OptionIndex() int {
+	return 1
+}
+
+func (survivalMode) Effects() StageEffects {
+	return StageEffects{ButtonMode: "2", Door: "12", LowLedBase: 12, HighLed: "16", FullLed: "20"}
+}
+
+func (survivalMode) Init(m *Match) {
+	m.Gold = teamSizeInt(m.opt.Mode2InitGold, len(m.Member))
+}
+
+func (survivalMode) Start(m *Match) {
+	m.goldDropTime = m.opt.Mode2GoldDropInterval
+}
+
+func (survivalMode) Tick(m *Match, sec float64) {
+	if m.goldDropTime > 0 && m.RampageTime <= 0 {
+		m.goldDropTime -= sec
+		if m.goldDropTime <= 0 {
+			m.Gold -= teamSizeInt(m.opt.Mode2GoldDropRate, len(m.Member))
+			m.goldDropTime = m.opt.Mode2GoldDropInterval
+		}
+	}
+}
+
+func (survivalMode) TouchPunish(m *Match, p *Player) {
+	punish := m.scoring.touchGold(m.modeIndex(), len(m.Member))
+	m.Gold = m.Gold - punish
+	p.LostGold += punish
+}
+
+func (survivalMode) NextStage(m *Match, s string) string {
+	if m.Gold <= 0 {
+		s = "after"
+	}
+	return s
+}
+
+func (survivalMode) TeamGrade(m *Match) string {
+	return m.opt.calcGrade(int(m.Elasped*1000), len(m.Member), m.opt.SurvivalTeamRank)
+}
+
+func (survivalMode) PersonGrade(m *Match, p *Player) string {
+	return m.opt.calcGrade(p.Gold-p.LostGold, len(m.Member), m.opt.SurvivalRank)
+}
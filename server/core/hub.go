@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"golang.org/x/net/websocket"
+	"log"
+	"net/http"
+	"os"
+)
+
+var _ = log.Printf
+
+// Hub runs several arenas in one process. Every arena is a Srv with its own
+// config, devices, laser pairs and queue, listening on its own tcp and udp
+// ports. The arenas share the database and the websocket endpoint, where a
+// client names its arena with ARENA on init.
+type Hub struct {
+	inbox  *Inbox
+	db     *DB
+	arenas map[string]*Srv
+	names  []string // in the order they were added, the first is the default
+	listen map[string][2]string
+}
+
+func NewHub() *Hub {
+	h := Hub{}
+	h.inbox = NewInbox()
+	h.db = NewDb()
+	h.arenas = make(map[string]*Srv)
+	h.names = make([]string, 0)
+	h.listen = make(map[string][2]string)
+	return &h
+}
+
+// AddArena adds the arena c.Arena whose arduinos connect to tcpAddr and
+// wearables to udpAddr, it must be called before Run
+func (h *Hub) AddArena(c *SrvConfig, tcpAddr string, udpAddr string) (*Srv, error) {
+	if _, ok := h.arenas[c.Arena]; ok {
+		return nil, fmt.Errorf("arena %q is added twice", c.Arena)
+	}
+	for name, addrs := range h.listen {
+		if addrs[0] == tcpAddr || addrs[1] == udpAddr {
+			return nil, fmt.Errorf("arena %q listens on the same address as arena %q", c.Arena, name)
+		}
+	}
+	s := newSrv(c, h.inbox, h.db)
+	h.arenas[c.Arena] = s
+	h.names = append(h.names, c.Arena)
+	h.listen[c.Arena] = [2]string{tcpAddr, udpAddr}
+	return s, nil
+}
+
+func (h *Hub) Run(dbPath string) {
+	if err := h.db.connect(dbPath); err != nil {
+		log.Printf("open database error:%v\n", err.Error())
+		os.Exit(1)
+	}
+	for _, name := range h.names {
+		s := h.arenas[name]
+		go s.listenTcp(h.listen[name][0])
+		go s.listenUdp(h.listen[name][1])
+		go s.mainLoop()
+	}
+	select {}
+}
+
+// Arena is the arena called name, the default one for an empty name and nil
+// for an unknown name
+func (h *Hub) Arena(name string) *Srv {
+	if name == "" && len(h.names) > 0 {
+		name = h.names[0]
+	}
+	return h.arenas[name]
+}
+
+func (h *Hub) Arenas() []string {
+	return h.names
+}
+
+func (h *Hub) ListenWebSocket(conn *websocket.Conn) {
+	log.Println("got new ws connection")
+	h.inbox.ListenConnection(NewInboxWsConnection(conn))
+}
+
+// ArenaHandler serves an http interface of the arena given by ?arena=
+func (h *Hub) ArenaHandler(fn func(s *Srv, c echo.Context) error) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		s := h.Arena(c.FormValue("arena"))
+		if s == nil {
+			d := make(map[string]interface{})
+			d["code"] = 1
+			d["error"] = fmt.Sprintf("场地%v不存在", c.FormValue("arena"))
+			return c.JSON(http.StatusOK, d)
+		}
+		return fn(s, c)
+	}
+}
+
+// GetArenas lists the arenas and the match each of them is running
+func (h *Hub) GetArenas(c echo.Context) error {
+	d := make([]Arena, len(h.names))
+	for i, name := range h.names {
+		d[i] = h.arenas[name].arena.status()
+	}
+	return c.JSON(http.StatusOK, d)
+}
@@ -1,17 +1,22 @@
 package core
 
 import (
+	"fmt"
 	"log"
 	"sync"
 )
 
 var _ = log.Println
 
+// Inbox holds the connections of every arena, a message read from a
+// connection goes to the receiver of the connection's arena
 type Inbox struct {
-	srv   *Srv
-	cdict map[int]*InboxClient
-	curID int
-	l     *sync.RWMutex
+	receivers map[string]func(*InboxMessage)
+	// defaultArena is used by connections that name no arena
+	defaultArena string
+	cdict        map[int]*InboxClient
+	curID        int
+	l            *sync.RWMutex
 }
 
 type p struct {
@@ -19,15 +24,34 @@ type p struct {
 	addr InboxAddress
 }
 
-func NewInbox(srv *Srv) *Inbox {
+func NewInbox() *Inbox {
 	inbox := Inbox{}
-	inbox.srv = srv
+	inbox.receivers = make(map[string]func(*InboxMessage))
 	inbox.cdict = make(map[int]*InboxClient)
 	inbox.curID = 1
 	inbox.l = new(sync.RWMutex)
 	return &inbox
 }
 
+// addArena must be called before the first connection is listened, the
+// first arena added is the default one
+func (inbox *Inbox) addArena(name string, receive func(*InboxMessage)) {
+	if len(inbox.receivers) == 0 {
+		inbox.defaultArena = name
+	}
+	inbox.receivers[name] = receive
+}
+
+// arena resolves the arena a connection declared, false for an unknown one
+// so that a misspelled ARENA never controls another arena
+func (inbox *Inbox) arena(name string) (string, bool) {
+	if name == "" {
+		return inbox.defaultArena, true
+	}
+	_, ok := inbox.receivers[name]
+	return name, ok
+}
+
 func (inbox *Inbox) ListenConnection(conn InboxConnection) {
 	inbox.l.Lock()
 	c := NewInboxClient(conn, inbox, inbox.curID)
@@ -45,14 +69,30 @@ func (inbox *Inbox) RemoveClient(id int) {
 	delete(inbox.cdict, id)
 }
 
-func (inbox *Inbox) ReceiveMessage(m *InboxMessage) {
-	inbox.srv.onInboxMessageArrived(m)
+// ReceiveMessage hands m to the receiver of arena, the messages of a
+// connection that declared an unknown arena are dropped
+func (inbox *Inbox) ReceiveMessage(m *InboxMessage, arena string) error {
+	name, ok := inbox.arena(arena)
+	if !ok {
+		return fmt.Errorf("未知的场地%v", arena)
+	}
+	inbox.receivers[name](m)
+	return nil
+}
+
+// Send writes msg to the connections of arena that accept one of addrs
+func (inbox *Inbox) Send(arena string, msg *InboxMessage, addrs []InboxAddress) {
+	inbox.SendExcept(arena, msg, addrs, nil)
 }
 
-func (inbox *Inbox) Send(msg *InboxMessage, addrs []InboxAddress) {
+// SendExcept is Send but skips the connections that accept one of except
+func (inbox *Inbox) SendExcept(arena string, msg *InboxMessage, addrs []InboxAddress, except []InboxAddress) {
 	inbox.l.RLock()
 	defer inbox.l.RUnlock()
 	for _, cli := range inbox.cdict {
+		if name, ok := inbox.arena(cli.Arena()); !ok || name != arena || acceptsAny(cli, except) {
+			continue
+		}
 		for _, addr := range addrs {
 			if cli.Accept(addr) {
 				cli.Write(msg)
@@ -60,3 +100,12 @@ func (inbox *Inbox) Send(msg *InboxMessage, addrs []InboxAddress) {
 		}
 	}
 }
+
+func acceptsAny(cli *InboxClient, addrs []InboxAddress) bool {
+	for _, addr := range addrs {
+		if cli.Accept(addr) {
+			return true
+		}
+	}
+	return false
+}
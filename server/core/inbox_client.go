@@ -30,6 +30,10 @@ func (c *InboxClient) Accept(addr InboxAddress) bool {
 	return c.conn.Accept(addr)
 }
 
+func (c *InboxClient) Arena() string {
+	return c.conn.Arena()
+}
+
 func (c *InboxClient) Write(msg *InboxMessage) {
 	go func() {
 		e := c.conn.WriteJSON(msg)
@@ -47,7 +51,10 @@ func (c *InboxClient) listenRead() {
 			log.Printf("read message error:%v\n", e.Error())
 		}
 		if !m.Empty() || m.RemoveAddress != nil || m.AddAddress != nil {
-			c.inbox.ReceiveMessage(m)
+			if err := c.inbox.ReceiveMessage(m, c.conn.Arena()); err != nil && m.AddAddress != nil {
+				log.Printf("warning: %v rejected, %v\n", m.AddAddress, err.Error())
+				c.Write(NewErrorInboxMessage(err.Error()))
+			}
 		}
 		if m.ShouldCloseConnection {
 			return
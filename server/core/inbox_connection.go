@@ -23,18 +23,21 @@ type InboxConnection interface {
 	WriteJSON(v *InboxMessage) error
 	Close() error
 	Accept(addr InboxAddress) bool
+	// Arena is the name of the arena the connection belongs to
+	Arena() string
 }
 
 type InboxTcpConnection struct {
 	conn    *net.TCPConn
+	arena   string
 	r       *bufio.Reader
 	id      string
 	ch      chan []byte
 	closeCh chan struct{}
 }
 
-func NewInboxTcpConnection(conn *net.TCPConn) *InboxTcpConnection {
-	tcp := InboxTcpConnection{conn: conn}
+func NewInboxTcpConnection(conn *net.TCPConn, arena string) *InboxTcpConnection {
+	tcp := InboxTcpConnection{conn: conn, arena: arena}
 	tcp.r = bufio.NewReader(conn)
 	tcp.ch = make(chan []byte, 1000)
 	tcp.closeCh = make(chan struct{})
@@ -155,6 +158,10 @@ func (tcp *InboxTcpConnection) doWrite() {
 	}
 }
 
+func (tcp *InboxTcpConnection) Arena() string {
+	return tcp.arena
+}
+
 func (tcp *InboxTcpConnection) Accept(addr InboxAddress) bool {
 	if addr.Type != at(tcp.id) {
 		return false
@@ -163,10 +170,11 @@ func (tcp *InboxTcpConnection) Accept(addr InboxAddress) bool {
 }
 
 type InboxUdpConnection struct {
-	conn *net.UDPConn
-	dict map[string]*udpClient
-	lock *sync.RWMutex
-	rmCh chan *udpClient
+	conn  *net.UDPConn
+	arena string
+	dict  map[string]*udpClient
+	lock  *sync.RWMutex
+	rmCh  chan *udpClient
 }
 
 type udpClient struct {
@@ -175,8 +183,8 @@ type udpClient struct {
 	id   string
 }
 
-func NewInboxUdpConnection(conn *net.UDPConn) *InboxUdpConnection {
-	u := InboxUdpConnection{conn: conn}
+func NewInboxUdpConnection(conn *net.UDPConn, arena string) *InboxUdpConnection {
+	u := InboxUdpConnection{conn: conn, arena: arena}
 	u.dict = make(map[string]*udpClient)
 	u.lock = new(sync.RWMutex)
 	u.rmCh = make(chan *udpClient, 1024)
@@ -248,6 +256,10 @@ func (udp *InboxUdpConnection) WriteJSON(v *InboxMessage) error {
 	return nil
 }
 
+func (udp *InboxUdpConnection) Arena() string {
+	return udp.arena
+}
+
 func (udp *InboxUdpConnection) Accept(addr InboxAddress) bool {
 	if addr.Type != InboxAddressTypeWearableDevice {
 		return false
@@ -285,10 +297,11 @@ func (udp *InboxUdpConnection) ping(c *udpClient) {
 }
 
 type InboxWsConnection struct {
-	conn *websocket.Conn
-	t    InboxAddressType
-	id   string
-	l    *sync.RWMutex
+	conn  *websocket.Conn
+	t     InboxAddressType
+	id    string
+	arena string // ARENA of the first init, empty for the default arena
+	l     *sync.RWMutex
 }
 
 func NewInboxWsConnection(conn *websocket.Conn) *InboxWsConnection {
@@ -313,6 +326,7 @@ func (ws *InboxWsConnection) ReadJSON(v *InboxMessage) error {
 		tt, _ := strconv.Atoi(v.Get("TYPE").(string))
 		t := InboxAddressType(tt)
 		id := v.GetStr("ID")
+		ws.setArena(v.GetStr("ARENA"))
 		oldid, oldt := ws.getAddressInfo()
 		if oldid != id {
 			v.AddAddress = &InboxAddress{t, id}
@@ -345,6 +359,24 @@ func (ws *InboxWsConnection) Accept(addr InboxAddress) bool {
 	return false
 }
 
+func (ws *InboxWsConnection) Arena() string {
+	ws.l.RLock()
+	defer ws.l.RUnlock()
+	return ws.arena
+}
+
+// setArena keeps the arena of the first init, a connection never moves to
+// another arena
+func (ws *InboxWsConnection) setArena(arena string) {
+	ws.l.Lock()
+	defer ws.l.Unlock()
+	if ws.id == "" {
+		ws.arena = arena
+	} else if arena != ws.arena {
+		log.Printf("warning: %v can not move from arena %q to %q\n", ws.id, ws.arena, arena)
+	}
+}
+
 func (ws *InboxWsConnection) getAddressInfo() (string, InboxAddressType) {
 	ws.l.RLock()
 	defer ws.l.RUnlock()
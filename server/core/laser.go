@@ -10,8 +10,6 @@ const laserSize = 10
 
 var _ = log.Printf
 
-var catchByPos = false
-
 type LaserLine struct {
 	ID      string
 	Index   int
@@ -25,8 +23,8 @@ type Laser struct {
 	DisplayP             RP      `json:"displayP"`
 	DisplayP2            RP      `json:"displayP2"`
 	player               *Player
-	dest                 int
-	pathMap              map[int]int
+	chase                ChaseStrategy
+	roam                 ChaseStrategy // while the wearable of player is out
 	p                    int
 	p2                   int
 	match                *Match
@@ -36,24 +34,24 @@ type Laser struct {
 	startupLines         []*LaserLine
 	startupingIndex      int
 	closed               bool
+	suspended            bool
 }
 
 func NewLaser(p P, player *Player, match *Match) *Laser {
 	l := Laser{}
 	l.IsPause = true
 	l.player = player
-	l.dest = -1
 	l.match = match
-	l.pathMap = make(map[int]int)
-	l.p = GetOptions().TilePosToInt(p)
+	l.chase = match.newChaseStrategy()
+	l.p = match.opt.TilePosToInt(p)
 	l.p2 = -1
 	l.convertDisplay()
-	l.elaspedSinceLastMove = GetOptions().LaserSpeed
+	l.elaspedSinceLastMove = match.opt.LaserSpeed
 	l.lines = make([]*LaserLine, 0)
 	l.startupLines = l.linesByP(l.p)
 	l.startupingIndex = 0
 	l.closed = false
-	l.Warning = GetOptions().LaserAppearTime
+	l.Warning = match.opt.LaserAppearTime
 	match.musicControlByCell(p.X, p.Y, "4")
 	match.srv.ledControlByCell(p.X, p.Y, "24")
 	return &l
@@ -82,44 +80,89 @@ func (l *Laser) Pause(t float64) int {
 	return l.p
 }
 
+func (l *Laser) Tile() int {
+	return l.match.opt.Conv(l.p)
+}
+
 func (l *Laser) IsFollow(cid string) bool {
 	return l.player.ControllerID == cid
 }
 
 func (l *Laser) Close() {
 	l.closed = true
-	l.doClose()
+	if !l.suspended {
+		l.doClose()
+	}
+}
+
+func (l *Laser) Suspend() {
+	if l.closed || l.suspended {
+		return
+	}
+	l.suspended = true
+	if !l.IsPause {
+		l.doClose()
+	}
+}
+
+// Resume reopens the lines, receivers need a moment to see them again so
+// they get the same grace as a newly opened line
+func (l *Laser) Resume() {
+	if l.closed || !l.suspended {
+		return
+	}
+	l.suspended = false
+	if !l.IsPause {
+		for _, line := range l.lines {
+			line.elasped = 0
+		}
+		l.doOpen()
+	}
 }
 
 func (l *Laser) IsTouched(m map[string]bool) (touched bool, p int, senderID string) {
 	p = 0
 	touched = false
 	senderID = ""
-	if l.IsPause || l.isStartuping() {
+	if l.IsPause || l.suspended || l.isStartuping() {
 		return
 	}
-	for k, v := range m {
-		if v {
+	// walk the lines in order so the same receivers always give the same tile
+	for _, line := range l.lines {
+		if line.elasped < 1000 {
 			continue
 		}
-		for _, line := range l.lines {
-			if line.elasped < 1000 {
-				continue
-			}
-			info := GetLaserPair().Get(line.ID, line.Index)
-			if info != nil && info.Valid > 0 && (info.ID+":"+info.Idx) == k {
-				p = line.P
-				senderID = line.ID + ":" + strconv.Itoa(line.Index)
-				touched = true
-				return
-			}
+		info := l.match.laserPair.Get(line.ID, line.Index)
+		if info == nil || info.Valid <= 0 {
+			continue
+		}
+		if isOn, ok := m[info.ID+":"+info.Idx]; ok && !isOn {
+			p = line.P
+			senderID = line.ID + ":" + strconv.Itoa(line.Index)
+			touched = true
+			return
 		}
 	}
 	return
 }
 
+// CoveredLines counts the lines on tile p that a player can no longer dodge,
+// the same lines that IsTouched looks at
+func (l *Laser) CoveredLines(p int) int {
+	if l.IsPause || l.suspended || l.isStartuping() {
+		return 0
+	}
+	n := 0
+	for _, line := range l.lines {
+		if line.P == p && line.elasped >= 1000 {
+			n += 1
+		}
+	}
+	return n
+}
+
 func (l *Laser) Tick(dt float64) {
-	opt := GetOptions()
+	opt := l.match.opt
 	if l.closed {
 		return
 	}
@@ -157,7 +200,7 @@ func (l *Laser) Tick(dt float64) {
 		l.lines = append(l.lines, line)
 		l.startupingIndex += 1
 	} else {
-		if l.player.Offline > 0 {
+		if l.player.Offline > 0 && l.player.dropoutPolicy == "" {
 			return
 		}
 		next := l.findPath()
@@ -208,7 +251,7 @@ func (l *Laser) musicControlByPos(p int, music string) {
 	if p < 0 {
 		return
 	}
-	tp := opt.IntToTile(p)
+	tp := l.match.opt.IntToTile(p)
 	l.match.musicControlByCell(tp.X, tp.Y, music)
 }
 
@@ -227,7 +270,7 @@ func (l *Laser) doOpen() {
 }
 
 func (l *Laser) linesByP(p int) []*LaserLine {
-	infos := GetOptions().mainArduinoInfosByPos(p)
+	infos := l.match.opt.mainArduinoInfosByPos(p)
 	ret := make([]*LaserLine, 0)
 	for _, info := range infos {
 		for i := 0; i < info.LaserNum; i++ {
@@ -238,7 +281,7 @@ func (l *Laser) linesByP(p int) []*LaserLine {
 }
 
 func (l *Laser) convertDisplay() {
-	opt := GetOptions()
+	opt := l.match.opt
 	y := l.p / opt.ArenaWidth
 	x := l.p % opt.ArenaWidth
 	y = opt.ArenaHeight - 1 - y
@@ -267,45 +310,30 @@ func (l *Laser) isStartuping() bool {
 }
 
 func (l *Laser) findPath() int {
-	opt := GetOptions()
-	l.fillPath()
+	opt := l.match.opt
 	pp1 := opt.Conv(l.p)
+	chase := l.chase
+	if l.player.Offline > 0 && l.player.dropoutPolicy == DropoutPolicyPatrol {
+		if l.roam == nil {
+			l.roam = &patrolChase{roam: true}
+		}
+		chase = l.roam
+	}
+	c := &ChaseContext{l.match, l, pp1, opt.Conv(opt.TilePosToInt(l.player.tilePos))}
+	next := chase.Next(c)
 	if l.p2 >= 0 {
+		// finish the move that is half done, forward unless p2 is farther
 		pp2 := opt.Conv(l.p2)
-		if l.pathMap[pp2] <= l.pathMap[pp1] {
-			return l.p2
-		} else {
+		if d, ok := chase.(chaseDistance); ok {
+			if d.Distance(c, pp2) <= d.Distance(c, pp1) {
+				return l.p2
+			}
 			return l.p
 		}
-	}
-	next, min := pp1, l.pathMap[pp1]
-	for _, i := range opt.TileAdjacency[pp1] {
-		if l.pathMap[i] < min {
-			min = l.pathMap[i]
-			next = i
+		if next == pp2 {
+			return l.p2
 		}
+		return l.p
 	}
 	return opt.Conv(next)
 }
-
-func (l *Laser) fillPath() {
-	opt := GetOptions()
-	dest := opt.TilePosToInt(l.player.tilePos)
-	if l.dest == dest {
-		return
-	}
-	l.dest = dest
-	for i := 0; i < opt.ArenaWidth*opt.ArenaHeight; i++ {
-		l.pathMap[i] = 10000
-	}
-	var fill func(x int, v int)
-	fill = func(x int, v int) {
-		l.pathMap[x] = v
-		for _, i := range opt.TileAdjacency[x] {
-			if l.pathMap[i] > v+1 {
-				fill(i, v+1)
-			}
-		}
-	}
-	fill(opt.Conv(l.dest), 0)
-}
@@ -5,4 +5,10 @@ type LaserInterface interface {
 	IsFollow(cid string) bool
 	Tick(dt float64)
 	Close()
+	// Tile is where the laser is as an index of MatchOptions.TileAdjacency
+	Tile() int
+	// Suspend turns the laser off while the match is paused, Resume turns
+	// it back on where it was
+	Suspend()
+	Resume()
 }
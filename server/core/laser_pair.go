@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -21,36 +22,32 @@ type ReceiverInfo struct {
 type _laserMap map[string]*ReceiverInfo
 
 type LaserPair struct {
-	m _laserMap
+	m    _laserMap
+	path string
 }
 
-var laserPair = loadLaserPair()
-
-func GetLaserPair() *LaserPair {
-	return laserPair
-}
-
-func loadLaserPair() *LaserPair {
+// LoadLaserPair reads the pairing saved at path, a missing file gives an
+// empty pairing which is saved to path once lasers are recorded
+func LoadLaserPair(path string) (*LaserPair, error) {
 	m := make(_laserMap)
-	b, e := ioutil.ReadFile("./laser.json")
+	b, e := ioutil.ReadFile(path)
 	if os.IsNotExist(e) {
-		return newLaserPair(m)
+		return newLaserPair(m, path), nil
 	}
 	if e != nil {
-		log.Printf("parse laser pair error:%v\n", e.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("parse laser pair error:%v", e.Error())
 	}
 	e = json.Unmarshal(b, &m)
 	if e != nil {
-		log.Printf("parse laser pair error:%v\n", e.Error())
-		os.Exit(1)
+		return nil, fmt.Errorf("parse laser pair error:%v", e.Error())
 	}
-	return newLaserPair(m)
+	return newLaserPair(m, path), nil
 }
 
-func newLaserPair(m _laserMap) *LaserPair {
+func newLaserPair(m _laserMap, path string) *LaserPair {
 	lp := LaserPair{}
 	lp.m = m
+	lp.path = path
 	receiverKeys := make(map[string]string)
 	for sender, info := range m {
 		k := info.ID + ":" + info.Idx
@@ -68,7 +65,7 @@ func (l *LaserPair) Save() {
 	b, _ := json.Marshal(l.m)
 	var out bytes.Buffer
 	json.Indent(&out, b, "", "  ")
-	ioutil.WriteFile("./laser.json", out.Bytes(), 0640)
+	ioutil.WriteFile(l.path, out.Bytes(), 0640)
 }
 
 func (l *LaserPair) GetValidReceivers(value bool) map[string]bool {
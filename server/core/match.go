@@ -6,6 +6,7 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,12 +19,15 @@ type MatchEventType int
 const (
 	MatchEventTypeEnd = iota
 	MatchEventTypeUpdate
+	MatchEventTypeState
 )
 
 const (
 	WarmupTriggerButtonNotStart = -1.0
 )
 
+const matchTickInterval = 33 * time.Millisecond
+
 type MatchEvent struct {
 	Type MatchEventType
 	ID   uint
@@ -42,37 +46,56 @@ type laserCommand struct {
 }
 
 type Match struct {
-	Member         []*Player        `json:"member"`
-	Stage          string           `json:"stage"`
-	TotalTime      float64          `json:"totalTime"`
-	Elasped        float64          `json:"elasped"`
-	WarmupTime     float64          `json:"warmupTime"`
-	RampageTime    float64          `json:"rampageTime"`
-	Mode1MaxTime   float64          `json:"mode1MaxTime"`
-	Mode           string           `json:"mode"`
-	Gold           int              `json:"gold"`
-	Energy         float64          `json:"energy"`
-	OnButtons      map[string]bool  `json:"onButtons"`
-	RampageCount   int              `json:"rampageCount"`
-	Lasers         []LaserInterface `json:"lasers"`
-	ID             uint             `json:"id"`
-	TeamID         string           `json:"teamID"`
-	MaxEnergy      float64          `json:"maxEnergy"`
-	MaxRampageTime float64          `json:"maxRampageTime"`
-	IsSimulator    int              `json:"isSimulator"`
+	Member         []*Player         `json:"member"`
+	Stage          string            `json:"stage"`
+	TotalTime      float64           `json:"totalTime"`
+	Elasped        float64           `json:"elasped"`
+	WarmupTime     float64           `json:"warmupTime"`
+	RampageTime    float64           `json:"rampageTime"`
+	Mode1MaxTime   float64           `json:"mode1MaxTime"`
+	Mode           string            `json:"mode"`
+	Profile        string            `json:"profile"`
+	Gold           int               `json:"gold"`
+	Energy         float64           `json:"energy"`
+	OnButtons      map[string]bool   `json:"onButtons"`
+	RampageCount   int               `json:"rampageCount"`
+	Lasers         []LaserInterface  `json:"lasers"`
+	ID             uint              `json:"id"`
+	TeamID         string            `json:"teamID"`
+	MaxEnergy      float64           `json:"maxEnergy"`
+	MaxRampageTime float64           `json:"maxRampageTime"`
+	IsSimulator    int               `json:"isSimulator"`
+	Sides          []*Side           `json:"sides,omitempty"`
+	PausedStage    string            `json:"pausedStage,omitempty"`
+	PowerUps       map[string]string `json:"powerUps"`             // button id to power-up kind
+	DoubleGold     map[int]float64   `json:"doubleGold,omitempty"` // side to seconds left
+	Practice       *PracticeState    `json:"practice,omitempty"`
 
+	mode          GameMode
 	offButtons    []string
 	hiddenButtons map[string]*float64
 	goldDropTime  float64
 	opt           *MatchOptions
+	laserPair     *LaserPair
 	srv           *Srv
 	msgCh         chan *InboxMessage
 	closeCh       chan bool
 	laserCmdCh    chan *laserCommand
 	matchData     *MatchData
+	clock         Clock
+	rand          *rand.Rand
+	ticks         int
+	currentPause  *PauseData
+	dropouts      map[string]*dropout // by controller id, while the wearable is offline
+	adjustedGold  int                 // gold the staff gave or took, it belongs to no player
+	recorder      *matchRecorder
+	scoring       *ScoringRules
+	samples       []TimelineSample
+	sampleRemain  float64
 	isSimulator   bool
 	laserStatus   map[int]bool
 	syncCount     int
+	stateRemain   time.Duration
 	receiverMap   map[string]bool
 	// 热身阶段相关状态
 	currentWarmupStage        int
@@ -80,7 +103,7 @@ type Match struct {
 	warmupCellButtonStatus    []bool
 }
 
-func NewMatch(s *Srv, controllerIDs []string, matchData *MatchData, mode string, teamID string, isSimulator bool) *Match {
+func NewMatch(s *Srv, opt *MatchOptions, controllerIDs []string, matchData *MatchData, mode string, teamID string, isSimulator bool) *Match {
 	m := Match{}
 	m.srv = s
 	m.Member = make([]*Player, len(controllerIDs))
@@ -89,15 +112,24 @@ func NewMatch(s *Srv, controllerIDs []string, matchData *MatchData, mode string,
 	}
 	m.ID = matchData.ID
 	m.matchData = matchData
+	m.clock = s.clock
+	m.rand = rand.New(rand.NewSource(matchData.Seed))
 	m.Stage = "before"
-	m.opt = GetOptions()
+	m.opt = opt
+	m.Profile = opt.Profile
+	m.laserPair = s.laserPair
 	m.Mode1MaxTime = m.opt.Mode1TotalTime
 	m.Mode = mode
-	m.receiverMap = GetLaserPair().GetValidReceivers(false)
+	m.mode = GetGameMode(mode)
+	m.receiverMap = m.laserPair.GetValidReceivers(false)
+	m.PowerUps = make(map[string]string)
+	m.DoubleGold = make(map[int]float64)
+	m.dropouts = make(map[string]*dropout)
+	m.scoring = newScoringRules(opt)
 	m.msgCh = make(chan *InboxMessage, 1000)
 	m.closeCh = make(chan bool)
 	m.TeamID = teamID
-	m.MaxEnergy = GetOptions().MaxEnergy
+	m.MaxEnergy = m.opt.MaxEnergy
 	m.MaxRampageTime = m.opt.RampageTime[m.modeIndex()]
 	m.laserCmdCh = make(chan *laserCommand)
 	m.isSimulator = isSimulator
@@ -113,37 +145,66 @@ func NewMatch(s *Srv, controllerIDs []string, matchData *MatchData, mode string,
 }
 
 func (m *Match) Run() {
-	dt := 33 * time.Millisecond
-	tickChan := time.Tick(dt)
-	if m.Mode == "g" {
-		m.TotalTime = m.opt.Mode1TotalTime
-	} else {
-		m.Gold = m.opt.Mode2InitGold[len(m.Member)-1]
+	tickChan := m.clock.Tick(matchTickInterval)
+	m.Start()
+	for {
+		<-tickChan
+		if !m.Step() {
+			break
+		}
+	}
+	d := make(map[string]interface{})
+	d["matchData"] = m.dumpMatchData()
+	d["teamID"] = m.TeamID
+	if m.Practice != nil {
+		d["practice"] = m.Practice
 	}
+	m.srv.onMatchEvent(MatchEvent{MatchEventTypeEnd, m.ID, d})
+	close(m.closeCh)
+}
+
+// Start enters warmup, Run calls it before the first tick
+func (m *Match) Start() {
+	m.startRecording()
+	m.mode.Init(m)
 	if m.isSimulator {
 		for _, member := range m.Member {
 			member.Pos = m.opt.RealPosition(m.opt.ArenaEntrance)
 		}
 	}
 	m.WarmupTime = m.opt.Warmup
+	if m.guided() != nil {
+		// nothing to warm up for without lasers
+		m.WarmupTime = 0
+	}
 	m.setStage("warmup")
 	if !m.isSimulator {
 		go m.handleLaserCmd()
 	}
-	for {
-		<-tickChan
-		m.handleInputs()
-		if m.Stage == "after" || m.Stage == "stop" {
-			break
-		}
-		m.tick(dt)
-		m.sync()
+}
+
+// Step handles the pending inputs and runs one tick, it returns false once
+// the match is over. With a ManualClock advance the clock before each step.
+func (m *Match) Step() bool {
+	m.handleInputs()
+	if m.Stage == "after" || m.Stage == "stop" {
+		m.stopRecording()
+		return false
 	}
-	d := make(map[string]interface{})
-	d["matchData"] = m.dumpMatchData()
-	d["teamID"] = m.TeamID
-	m.srv.onMatchEvent(MatchEvent{MatchEventTypeEnd, m.ID, d})
-	close(m.closeCh)
+	m.tick(matchTickInterval)
+	m.sync()
+	m.publishState()
+	m.ticks += 1
+	if m.recorder != nil {
+		m.recorder.setTick(m.ticks)
+	}
+	return true
+}
+
+// Feed queues an input for the next Step in order, unlike OnMatchCmdArrived
+// it blocks while the queue is full
+func (m *Match) Feed(msg *InboxMessage) {
+	m.msgCh <- msg
 }
 
 func (m *Match) OnMatchCmdArrived(cmd *InboxMessage) {
@@ -156,7 +217,7 @@ func (m *Match) OnMatchCmdArrived(cmd *InboxMessage) {
 }
 
 func (m *Match) OnLaserInfoArrived(msg *InboxMessage) {
-	if m.isSimulator || GetOptions().CatchMode == 0 {
+	if m.isSimulator || m.opt.CatchMode == 0 {
 		return
 	}
 	m.OnMatchCmdArrived(msg)
@@ -184,7 +245,7 @@ func (m *Match) handleLaserCmd() {
 					*v -= 1
 					sendCmd = *v == 0
 					if *v < 0 {
-						log.Println("warning:laser count less than 0:%v\n", *v)
+						log.Printf("warning:laser count less than 0:%v\n", *v)
 						*v = 0
 					}
 				} else {
@@ -201,6 +262,9 @@ func (m *Match) handleLaserCmd() {
 }
 
 func (m *Match) tick(dt time.Duration) {
+	if m.Stage == stagePaused {
+		return
+	}
 	sec := dt.Seconds()
 	if m.isWarmup() {
 		m.WarmupTime = math.Max(m.WarmupTime-sec, 0)
@@ -226,7 +290,7 @@ func (m *Match) tick(dt time.Duration) {
 				m.warmupTriggerButtonRemain = m.opt.WarmupButtonInterval
 			}
 		}
-		if m.currentWarmupStage < len(m.opt.WarmupLasers) {
+		if m.currentWarmupStage < len(m.opt.WarmupLasers) && m.guided() == nil {
 			warmupLaser := m.opt.WarmupLasers[m.currentWarmupStage]
 			elasped := m.opt.Warmup - m.WarmupTime
 			if elasped*1000 >= float64(warmupLaser.Time) {
@@ -238,7 +302,7 @@ func (m *Match) tick(dt time.Duration) {
 						m.buttonControl(p, true)
 					}
 				}
-				m.srv.lasersControl(warmupLaser.Large[:], warmupLaser.Small[:])
+				m.srv.lasersControl(warmupLaser.Large, warmupLaser.Small)
 				m.currentWarmupStage += 1
 				if m.currentWarmupStage >= len(m.opt.WarmupLasers) {
 					log.Println("stop warmup effect")
@@ -248,33 +312,41 @@ func (m *Match) tick(dt time.Duration) {
 			}
 		}
 	} else if m.isOngoing() {
+		m.sampleTimeline(sec)
 		m.Elasped += sec
-		if m.Mode == "g" {
-			m.TotalTime = math.Max(m.TotalTime-sec, 0)
-		}
 		m.RampageTime = math.Max(m.RampageTime-sec, 0)
-		if m.Mode == "s" && m.goldDropTime > 0 && m.RampageTime <= 0 {
-			m.goldDropTime -= sec
-			if m.goldDropTime <= 0 {
-				m.Gold -= m.opt.Mode2GoldDropRate[len(m.Member)-1]
-				m.goldDropTime = m.opt.Mode2GoldDropInterval
-			}
-		}
+		m.decayEnergy(sec)
+		m.mode.Tick(m, sec)
+		m.powerUpTick(sec)
+		shown := make([]string, 0)
 		for k, v := range m.hiddenButtons {
 			*v -= sec
 			if *v <= 0 {
-				delete(m.hiddenButtons, k)
-				m.OnButtons[k] = true
-				m.setSingleButtonEffect(k)
+				shown = append(shown, k)
 			}
 		}
+		// in id order, a power-up may be picked for each of them
+		sort.Strings(shown)
+		for _, k := range shown {
+			delete(m.hiddenButtons, k)
+			m.OnButtons[k] = true
+			m.setSingleButtonEffect(k)
+			m.maybeSpawnPowerUp(k)
+		}
 	}
 	for _, player := range m.Member {
 		m.playerTick(player, sec)
 	}
+	if m.Stage == stagePaused {
+		// a dropout paused the match, the lasers are suspended already
+		return
+	}
 	for _, laser := range m.Lasers {
 		laser.Tick(sec)
 	}
+	if m.isOngoing() && !m.isSimulator && m.opt.CatchMode == 0 {
+		m.catchByPosition()
+	}
 	m.updateStage()
 }
 
@@ -282,91 +354,55 @@ func (m *Match) setStage(s string) {
 	if m.Stage == s {
 		return
 	}
+	fx := m.mode.Effects()
 	switch s {
 	case "warmup":
 		m.srv.bgControl(m.opt.BgWarmup[m.modeIndex()])
 		m.srv.ledControl(3, "0", "1", "2", "3")
-		if m.Mode == "g" {
-			m.srv.doorControl("5", "5", "D-1")
-			m.srv.doorControl("5", "5", "D-2")
-			m.srv.doorControl("", "5", "D-3")
-			m.srv.doorControl("", "5", "D-4")
-		} else {
-			m.srv.doorControl("12", "12", "D-1")
-			m.srv.doorControl("12", "12", "D-2")
-			m.srv.doorControl("", "12", "D-3")
-			m.srv.doorControl("", "12", "D-4")
-		}
+		m.srv.doorControl(fx.Door, fx.Door, "D-1")
+		m.srv.doorControl(fx.Door, fx.Door, "D-2")
+		m.srv.doorControl("", fx.Door, "D-3")
+		m.srv.doorControl("", fx.Door, "D-4")
 	case "ongoing-low-0":
-		if m.Mode == "g" {
-			m.srv.doorControl("5", "5", "D-1")
-			m.srv.doorControl("5", "5", "D-2")
-			m.srv.doorControl("", "5", "D-3")
-			m.srv.doorControl("", "5", "D-4")
-		} else {
-			m.srv.doorControl("12", "12", "D-1")
-			m.srv.doorControl("12", "12", "D-2")
-			m.srv.doorControl("", "12", "D-3")
-			m.srv.doorControl("", "12", "D-4")
-		}
+		m.srv.doorControl(fx.Door, fx.Door, "D-1")
+		m.srv.doorControl(fx.Door, fx.Door, "D-2")
+		m.srv.doorControl("", fx.Door, "D-3")
+		m.srv.doorControl("", fx.Door, "D-4")
 		m.srv.lightControl("1")
 		if m.Stage == "ongoing-rampage" {
 			msg := NewInboxMessage()
 			msg.SetCmd("btn_ctrl")
 			msg.Set("useful", "0")
-			if m.Mode == "g" {
-				msg.Set("mode", "1")
-			} else {
-				msg.Set("mode", "2")
-			}
+			msg.Set("mode", fx.ButtonMode)
 			msg.Set("stage", "0")
 			m.srv.sends(msg, InboxAddressTypeMainArduinoDevice)
 			m.initButtons()
 		} else if m.isWarmup() {
-			if m.Mode == "s" {
-				m.goldDropTime = m.opt.Mode2GoldDropInterval
+			m.mode.Start(m)
+			if g := m.guided(); g != nil {
+				g.InitButtons(m)
+			} else {
+				m.initLasers()
+				m.initButtons()
 			}
-			m.initLasers()
-			m.initButtons()
 		}
 		m.srv.bgControl(m.opt.BgNormal[m.modeIndex()])
-		if m.Mode == "g" {
-			m.srv.ledControl(3, "5")
-			m.srv.ledControl(1, "0", "2", "3")
-		} else {
-			m.srv.ledControl(3, "12")
-			m.srv.ledControl(1, "0", "2", "3")
-		}
+		m.srv.ledControl(3, fx.Door)
+		m.srv.ledControl(1, "0", "2", "3")
 	case "ongoing-low-1":
 	case "ongoing-low-2":
 	case "ongoing-low-3":
 		level, _ := strconv.Atoi(strings.Split(s, "-")[2])
-		var mode string
-		if m.Mode == "g" {
-			mode = strconv.Itoa(level + 5)
-		} else {
-			mode = strconv.Itoa(level + 12)
-		}
-		m.srv.ledControl(3, mode)
+		m.srv.ledControl(3, strconv.Itoa(level+fx.LowLedBase))
 	case "ongoing-high":
 		m.srv.lightControl("2")
 		m.srv.bgControl(m.opt.BgHigh[m.modeIndex()])
-		if m.Mode == "g" {
-			m.srv.doorControl("9", "", "D-1")
-			m.srv.doorControl("9", "", "D-2")
-			m.srv.ledControl(3, "9")
-		} else {
-			m.srv.doorControl("16", "", "D-1")
-			m.srv.doorControl("16", "", "D-2")
-			m.srv.ledControl(3, "16")
-		}
+		m.srv.doorControl(fx.HighLed, "", "D-1")
+		m.srv.doorControl(fx.HighLed, "", "D-2")
+		m.srv.ledControl(3, fx.HighLed)
 	case "ongoing-full":
 		m.srv.bgControl(m.opt.BgFull[m.modeIndex()])
-		if m.Mode == "g" {
-			m.srv.ledControl(3, "19")
-		} else {
-			m.srv.ledControl(3, "20")
-		}
+		m.srv.ledControl(3, fx.FullLed)
 	case "ongoing-rampage":
 		m.srv.bgControl(m.opt.BgRampage[m.modeIndex()])
 		m.srv.lightControl("0")
@@ -386,6 +422,7 @@ func (m *Match) setStage(s string) {
 		}
 		m.offButtons = make([]string, len(offButtons))
 		m.hiddenButtons = make(map[string]*float64)
+		m.PowerUps = make(map[string]string)
 		addrs := make([]InboxAddress, len(offButtons))
 		offIdx := 0
 		for _, btn := range m.opt.Buttons {
@@ -402,7 +439,7 @@ func (m *Match) setStage(s string) {
 		m.RampageCount += 1
 		for _, player := range m.Member {
 			player.Combo = 0
-			player.lastHitTime = time.Unix(0, 0)
+			player.lastHitAt = -1
 		}
 		m.srv.ledRampageEffect(offButtons)
 	case "ongoing-countdown":
@@ -410,6 +447,8 @@ func (m *Match) setStage(s string) {
 		m.srv.ledControl(1, "47")
 		m.srv.ledControl(2, "46")
 	case "after", "stop":
+		m.endPause()
+		m.endDropouts()
 		m.srv.bgControl(m.opt.BgLeave[m.modeIndex()])
 		m.srv.doorControl("23", "1", "D-1")
 		m.srv.doorControl("46", "1", "D-2")
@@ -424,7 +463,15 @@ func (m *Match) setStage(s string) {
 		m.srv.setWallM2M3Auto(false)
 		m.srv.ledFlowEffect()
 	}
+	m.switchStage(s)
+}
+
+// switchStage changes the stage without the effects of entering it, which
+// setStage plays first. pause and resume use it alone as they keep the
+// effects, timers and buttons of the stage they leave and return to.
+func (m *Match) switchStage(s string) {
 	log.Printf("game stage:%v\n", s)
+	m.feedStage(m.Stage, s)
 	m.Stage = s
 }
 
@@ -444,45 +491,39 @@ func (m *Match) updateStage() {
 	} else if level < 5 {
 		s = "ongoing-high"
 	} else {
-		if len(m.Member) == 1 {
-			s = "ongoing-rampage"
-		} else {
-			together := true
-			if m.isSimulator {
-				p, pBool := m.opt.TilePosition(m.Member[0].Pos)
-				if pBool {
-					for i := 1; i < len(m.Member); i++ {
-						pp, ppBool := m.opt.TilePosition(m.Member[i].Pos)
-						if !ppBool || pp.X != p.X || pp.Y != p.Y {
-							together = false
-							break
-						}
-					}
-				} else {
-					together = false
-				}
-			} else {
-				tp := m.Member[0].tilePos
-				for i := 1; i < len(m.Member); i++ {
-					if m.Member[i].tilePos.X != tp.X || m.Member[i].tilePos.Y != tp.Y {
-						together = false
-					}
-				}
-			}
-			if together {
-				s = "ongoing-rampage"
-			} else {
-				s = "ongoing-full"
+		s = "ongoing-full"
+	}
+	if m.scoring.canRampage(m.Energy, m.opt.MaxEnergy, m.together(m.Member)) {
+		s = "ongoing-rampage"
+	}
+	m.setStage(m.mode.NextStage(m, s))
+}
+
+// together reports whether players all stand on the same tile
+func (m *Match) together(players []*Player) bool {
+	if len(players) <= 1 {
+		return true
+	}
+	if m.isSimulator {
+		p, pBool := m.opt.TilePosition(players[0].Pos)
+		if !pBool {
+			return false
+		}
+		for i := 1; i < len(players); i++ {
+			pp, ppBool := m.opt.TilePosition(players[i].Pos)
+			if !ppBool || pp.X != p.X || pp.Y != p.Y {
+				return false
 			}
 		}
+		return true
 	}
-	if m.Mode == "g" && s != "ongoing-rampage" && m.TotalTime < m.opt.Mode1CountDown {
-		s = "ongoing-countdown"
-	}
-	if m.Mode == "g" && m.TotalTime <= 0 || m.Mode == "s" && m.Gold <= 0 {
-		s = "after"
+	tp := players[0].tilePos
+	for i := 1; i < len(players); i++ {
+		if players[i].tilePos.X != tp.X || players[i].tilePos.Y != tp.Y {
+			return false
+		}
 	}
-	m.setStage(s)
+	return true
 }
 
 func (m *Match) openLaser(ID string, idx int) {
@@ -537,6 +578,9 @@ func (m *Match) handleInputs() bool {
 }
 
 func (m *Match) handleInput(msg *InboxMessage) {
+	if m.recorder != nil {
+		m.recorder.input(msg)
+	}
 	if msg.RemoveAddress != nil {
 		m.playerOffline(msg.RemoveAddress.String())
 		return
@@ -549,6 +593,12 @@ func (m *Match) handleInput(msg *InboxMessage) {
 	switch cmd {
 	case "stopMatch":
 		m.setStage("stop")
+	case "pauseMatch":
+		m.pause()
+	case "resumeMatch":
+		m.resume()
+	case "adjustMatch":
+		m.adjust(msg)
 	case "playerMove":
 		if player := m.getPlayer(msg.Address.String()); player != nil {
 			player.moving = true
@@ -565,7 +615,7 @@ func (m *Match) handleInput(msg *InboxMessage) {
 			}
 			loc, _ := strconv.Atoi(msg.GetStr("loc"))
 			if loc > 0 {
-				player.updateLoc(loc)
+				m.observeWearable(player, loc)
 			}
 		}
 	case "upload_score":
@@ -573,11 +623,8 @@ func (m *Match) handleInput(msg *InboxMessage) {
 			break
 		}
 		info := arduinoInfoFromID(msg.Address.ID)
-		for _, player := range m.Member {
-			if player.tilePos.X == info.X-1 && player.tilePos.Y == info.Y-1 {
-				m.consumeButton(info.ID, player, msg.GetStr("score"))
-				break
-			}
+		if player := m.observeButton(m.opt.TilePosToInt(P{info.X - 1, info.Y - 1})); player != nil {
+			m.consumeButton(info.ID, player, msg.GetStr("score"))
 		}
 		m.onButtonPressed(info.ID)
 	case "hb":
@@ -598,31 +645,89 @@ func (m *Match) handleInput(msg *InboxMessage) {
 				}
 			}
 		}
-		if changed {
+		if changed && m.Stage != stagePaused {
 			musicPostions := make(map[int]bool)
+			blockedTiles := make([]int, 0)
 			for _, laser := range m.Lasers {
 				l := laser.(*Laser)
 				blocked, p, _ := l.IsTouched(m.receiverMap)
 				if blocked {
-					shouldPause := false
-					for _, player := range m.Member {
-						pp := GetOptions().TilePosToInt(player.tilePos)
-						if pp == p && player.InvincibleTime <= 0 {
-							musicPostions[pp] = true
-							m.touchPunish(player)
-							shouldPause = true
-						}
-					}
-					if shouldPause {
-						l.Pause(GetOptions().LaserPauseTime)
-					}
+					blockedTiles = append(blockedTiles, p)
 				}
+				if blocked && m.catchOnTile(p, musicPostions) {
+					l.Pause(m.opt.LaserPauseTime)
+				}
+			}
+			// catch on the tiles from before these lines were observed
+			for _, p := range blockedTiles {
+				m.observeBlocked(p)
+			}
+			m.playCatchMusic(musicPostions)
+		}
+	}
+}
+
+// catchOnTile punishes the players on tile p that are not invincible and
+// tells whether anyone was caught
+func (m *Match) catchOnTile(p int, musicPostions map[int]bool) bool {
+	caught := false
+	for _, player := range m.Member {
+		pp := m.opt.TilePosToInt(player.tilePos)
+		if pp == p && player.InvincibleTime <= 0 && !player.estimate.inferred {
+			musicPostions[pp] = true
+			m.touchPunish(player)
+			caught = true
+		}
+	}
+	return caught
+}
+
+// catchByPosition is catchMode 0, the wearables tell where the players are
+// and a player is caught when enough lines of a laser cover the tile
+func (m *Match) catchByPosition() {
+	musicPostions := make(map[int]bool)
+	for _, laser := range m.Lasers {
+		l, ok := laser.(*Laser)
+		if !ok {
+			continue
+		}
+		shouldPause := false
+		for _, player := range m.Member {
+			// the tile of an offline wearable is stale
+			if player.Offline > 0 || player.InvincibleTime > 0 || player.Confidence < m.opt.CatchMinConfidence || player.estimate.inferred {
+				continue
 			}
-			for pos, _ := range musicPostions {
-				tilePos := GetOptions().IntToTile(pos)
-				m.srv.musicControlByCell(tilePos.X, tilePos.Y, "6")
+			p := m.opt.TilePosToInt(player.tilePos)
+			if l.CoveredLines(p) >= m.catchLaserNum(p) {
+				musicPostions[p] = true
+				m.touchPunish(player)
+				shouldPause = true
 			}
 		}
+		if shouldPause {
+			l.Pause(m.opt.LaserPauseTime)
+		}
+	}
+	m.playCatchMusic(musicPostions)
+}
+
+// catchLaserNum is catchLaserNum of the options, or every line of tile p if
+// it has fewer
+func (m *Match) catchLaserNum(p int) int {
+	total := 0
+	for _, info := range m.opt.mainArduinoInfosByPos(p) {
+		total += info.LaserNum
+	}
+	if total > 0 && total < m.opt.CatchLaserNum {
+		return total
+	}
+	return m.opt.CatchLaserNum
+}
+
+func (m *Match) playCatchMusic(musicPostions map[int]bool) {
+	for pos, _ := range musicPostions {
+		tilePos := m.opt.IntToTile(pos)
+		m.srv.musicControlByCell(tilePos.X, tilePos.Y, "6")
 	}
 }
 
@@ -639,6 +744,7 @@ func (m *Match) playerOffline(cid string) {
 	for _, player := range m.Member {
 		if player.ControllerID == cid {
 			player.setOffline()
+			m.startDropout(player)
 		}
 	}
 }
@@ -647,12 +753,19 @@ func (m *Match) playerOnline(cid string) {
 	for _, player := range m.Member {
 		if player.ControllerID == cid {
 			player.setOnline()
+			m.endDropout(player)
 		}
 	}
 }
 
 func (m *Match) playerTick(player *Player, sec float64) {
 	player.InvincibleTime = math.Max(player.InvincibleTime-sec, 0)
+	if player.Offline > 0 {
+		m.dropoutTick(player, sec)
+	}
+	if !m.isSimulator {
+		m.decayPosition(player, sec)
+	}
 	if m.isSimulator {
 		moved := player.UpdatePos(sec, m.opt)
 		if !m.isOngoing() {
@@ -689,6 +802,7 @@ func (m *Match) updatePlayerStatus(st string, p *Player) {
 
 func (m *Match) dumpMatchData() *MatchData {
 	m.matchData.Mode = m.Mode
+	m.matchData.Profile = m.Profile
 	m.matchData.Elasped = m.Elasped
 	m.matchData.Member = make([]PlayerData, 0)
 	m.matchData.RampageCount = m.RampageCount
@@ -696,7 +810,7 @@ func (m *Match) dumpMatchData() *MatchData {
 	m.matchData.TeamID = m.TeamID
 	m.matchData.ExternalID = ""
 	totalGold := 0
-	m.matchData.Grade = m.opt.TeamGrade(m.Gold, m.Elasped, len(m.Member), m.Mode)
+	m.matchData.Grade = m.mode.TeamGrade(m)
 	for _, player := range m.Member {
 		totalGold += player.Gold - player.LostGold
 		playerData := PlayerData{}
@@ -715,50 +829,95 @@ func (m *Match) dumpMatchData() *MatchData {
 		playerData.Answered = 0
 		playerData.ExternalID = ""
 		playerData.ControllerID = player.ControllerID
-		playerData.Grade = m.opt.PersonGrade(player.Gold-player.LostGold, len(m.Member), m.Mode)
+		playerData.Side = player.Side
+		playerData.PowerUps = player.PowerUps
+		playerData.MaxCombo = player.MaxCombo
+		playerData.Grade = m.mode.PersonGrade(m, player)
 		m.matchData.Member = append(m.matchData.Member, playerData)
 	}
-	m.matchData.Gold = totalGold
+	m.matchData.Gold = totalGold + m.adjustedGold
+	m.matchData.Timeline = m.dumpTimeline()
+	if len(m.Sides) > 0 {
+		winner := m.winnerSide()
+		m.matchData.Sides = make([]SideData, len(m.Sides))
+		for i, side := range m.Sides {
+			sd := SideData{}
+			sd.Side = side.Index
+			sd.Gold = side.Gold
+			sd.RampageCount = side.RampageCount
+			sd.Win = winner == side.Index
+			sd.Grade = m.opt.calcGrade(side.Gold, side.Size, m.opt.GoldTeamRank)
+			m.matchData.Sides[i] = sd
+		}
+	}
 	return m.matchData
 }
 
 func (m *Match) modeIndex() int {
-	if m.Mode == "g" {
-		return 0
-	} else {
-		return 1
-	}
+	return m.mode.OptionIndex()
 }
 
 func (m *Match) initLasers() {
 	m.Lasers = make([]LaserInterface, len(m.Member))
-	src := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(src)
-	l := r.Perm(m.opt.ArenaWidth * m.opt.ArenaHeight)
+	l := m.rand.Perm(m.opt.ArenaWidth * m.opt.ArenaHeight)
 	for i, player := range m.Member {
 		loc := l[i]
 		p := P{loc % m.opt.ArenaWidth, loc / m.opt.ArenaWidth}
-		if m.isSimulator {
-			m.Lasers[i] = NewSimuLaser(p, player, m)
-		} else {
-			m.Lasers[i] = NewLaser(p, player, m)
+		m.Lasers[i] = m.newLaser(p, player)
+	}
+}
+
+func (m *Match) newLaser(p P, player *Player) LaserInterface {
+	m.feed(FeedLaserSpawned, map[string]interface{}{"x": p.X, "y": p.Y, "cid": player.ControllerID})
+	if m.isSimulator {
+		return NewSimuLaser(p, player, m)
+	}
+	return NewLaser(p, player, m)
+}
+
+func (m *Match) newChaseStrategy() ChaseStrategy {
+	return newChaseStrategy(m.opt.chaseStrategyName(m.Mode))
+}
+
+func (m *Match) removeLaser(laser LaserInterface) {
+	laser.Close()
+	for i, l := range m.Lasers {
+		if l == laser {
+			m.Lasers = append(m.Lasers[:i], m.Lasers[i+1:]...)
+			return
 		}
 	}
 }
 
+// freeTile picks a random tile no player stands on
+func (m *Match) freeTile() P {
+	for _, loc := range m.rand.Perm(m.opt.ArenaWidth * m.opt.ArenaHeight) {
+		p := m.opt.IntToTile(loc)
+		taken := false
+		for _, player := range m.Member {
+			if player.tilePos == p {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return p
+		}
+	}
+	return m.opt.IntToTile(0)
+}
+
 func (m *Match) touchPunish(p *Player) {
-	opt := GetOptions()
+	opt := m.opt
 	p.InvincibleTime = opt.PlayerInvincibleTime
 	p.HitCount += 1
-	var punish int
-	playerCount := len(m.Member)
-	if m.Mode == "g" {
-		punish = opt.Mode1TouchPunish[playerCount-1]
-	} else {
-		punish = opt.Mode2TouchPunish[playerCount-1]
-	}
-	m.Gold = m.Gold - punish
-	p.LostGold += punish
+	m.mode.TouchPunish(m, p)
+	m.feed(FeedPlayerHit, map[string]interface{}{
+		"cid":        p.ControllerID,
+		"hitCount":   p.HitCount,
+		"lostGold":   p.LostGold,
+		"invincible": p.InvincibleTime,
+	})
 }
 
 func (m *Match) initButtons() {
@@ -769,13 +928,12 @@ func (m *Match) initButtons() {
 		player.ButtonTime = 0
 	}
 	count := len(m.opt.Buttons)
-	src := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(src)
-	randList := r.Perm(count)
-	n := m.opt.InitButtonNum[len(m.Member)-1]
+	randList := m.rand.Perm(count)
+	n := teamSizeInt(m.opt.InitButtonNum, len(m.Member))
 	m.OnButtons = make(map[string]bool)
 	m.offButtons = make([]string, count-n)
 	m.hiddenButtons = make(map[string]*float64)
+	m.PowerUps = make(map[string]string)
 	for i, j := range randList {
 		id := m.opt.Buttons[j].Id
 		if i < n {
@@ -803,11 +961,7 @@ func (m *Match) buttonControl(p int, isOn bool) {
 	} else {
 		msg.Set("useful", "0")
 	}
-	if m.Mode == "g" {
-		msg.Set("mode", "1")
-	} else {
-		msg.Set("mode", "2")
-	}
+	msg.Set("mode", m.mode.Effects().ButtonMode)
 	msg.Set("stage", "0")
 	m.srv.send(msg, addrs)
 }
@@ -831,11 +985,7 @@ func (m *Match) setButtonEffect(stage string, immediately bool) {
 		} else {
 			msg.Set("useful", "1")
 		}
-		if m.Mode == "g" {
-			msg.Set("mode", "1")
-		} else {
-			msg.Set("mode", "2")
-		}
+		msg.Set("mode", m.mode.Effects().ButtonMode)
 		msg.Set("stage", stage)
 		m.srv.send(msg, onAddrs)
 	}
@@ -843,11 +993,7 @@ func (m *Match) setButtonEffect(stage string, immediately bool) {
 		msg := NewInboxMessage()
 		msg.SetCmd("btn_ctrl")
 		msg.Set("useful", "0")
-		if m.Mode == "g" {
-			msg.Set("mode", "1")
-		} else {
-			msg.Set("mode", "2")
-		}
+		msg.Set("mode", m.mode.Effects().ButtonMode)
 		msg.Set("stage", stage)
 		m.srv.send(msg, offAddrs)
 	}
@@ -860,11 +1006,7 @@ func (m *Match) setSingleButtonEffect(id string) {
 	msg := NewInboxMessage()
 	msg.SetCmd("btn_ctrl")
 	msg.Set("useful", "1")
-	if m.Mode == "g" {
-		msg.Set("mode", "1")
-	} else {
-		msg.Set("mode", "2")
-	}
+	msg.Set("mode", m.mode.Effects().ButtonMode)
 	if strings.HasPrefix(m.Stage, "ongoing-low") {
 		msg.Set("stage", "0")
 	} else {
@@ -891,35 +1033,23 @@ func (m *Match) consumeButton(btn string, player *Player, lvl string) {
 		level = player.ButtonLevel
 	}
 	player.LevelData[level] += 1
+	if g := m.guided(); g != nil {
+		g.ButtonPressed(m, player, btn, level)
+	}
+	combo := player.Combo
 	if level > 0 {
-		bonus := m.opt.GoldBonus[m.modeIndex()]
-		m.Gold += bonus
-		player.Gold += bonus
-		if m.RampageTime <= 0 {
-			sec := time.Since(player.lastHitTime).Seconds()
-			player.lastHitTime = time.Now()
-			var max float64
-			if player.Combo == 0 {
-				max = m.opt.FirstComboInterval[len(m.Member)-1]
-			} else {
-				max = m.opt.FirstComboInterval[len(m.Member)-1]
-			}
-			if sec <= max {
-				player.Combo += 1
-			} else {
-				player.Combo = 0
-			}
-			extra := 0.0
-			if player.Combo == 1 {
-				extra = m.opt.FirstComboExtra
-				player.ComboCount += 1
-			} else if player.Combo > 1 {
-				extra = m.opt.ComboExtra
-			}
-			delta := m.opt.EnergyBonus[level][len(m.Member)-1] + extra
-			m.Energy = math.Min(m.opt.MaxEnergy, m.Energy+delta)
-			player.Energy += delta
-		}
+		m.mode.ConsumeButton(m, player, level)
+		m.triggerPowerUp(btn, player)
+	}
+	m.feed(FeedButtonPressed, map[string]interface{}{
+		"cid":    player.ControllerID,
+		"button": btn,
+		"level":  level,
+		"combo":  player.Combo,
+		"gold":   player.Gold,
+	})
+	if combo == 0 && player.Combo > 0 {
+		m.feed(FeedComboStarted, map[string]interface{}{"cid": player.ControllerID, "button": btn})
 	}
 	player.lastButton = btn
 	player.ButtonLevel = 0
@@ -927,12 +1057,32 @@ func (m *Match) consumeButton(btn string, player *Player, lvl string) {
 	player.ButtonTime = 0
 }
 
+// scoreButton adds the bonus of a button of level to gold and, unless the
+// team or side the gold and energy belong to is rampaging, the energy of it
+// and its combo to energy
+func (m *Match) scoreButton(player *Player, level int, rampage bool, gold *int, energy *float64) {
+	var combo *ComboRule
+	if !rampage {
+		// in match time, a pause doesn't eat into the combo window
+		sec := math.Inf(1)
+		if player.lastHitAt >= 0 {
+			sec = m.Elasped - player.lastHitAt
+		}
+		player.lastHitAt = m.Elasped
+		combo = m.scoring.hit(player, sec, len(m.Member))
+		delta := m.scoring.buttonEnergy(level, len(m.Member), combo)
+		*energy = math.Min(m.opt.MaxEnergy, *energy+delta)
+		player.Energy += delta
+	}
+	bonus := m.scoring.buttonGold(m.modeIndex(), level, combo) * m.goldFactor(player)
+	*gold += bonus
+	player.Gold += bonus
+}
+
 func (m *Match) onButtonPressed(btn string) {
-	if m.RampageTime <= 0 {
+	if m.RampageTime <= 0 && m.guided() == nil {
 		delete(m.OnButtons, btn)
-		src := rand.NewSource(time.Now().UnixNano())
-		r := rand.New(src)
-		i := r.Intn(len(m.offButtons))
+		i := m.rand.Intn(len(m.offButtons))
 		key := m.offButtons[i]
 		m.offButtons[i] = btn
 		t := m.opt.ButtonHideTime[m.modeIndex()]
@@ -0,0 +1,54 @@
+package core
+
+// types of the matchEvent feed
+const (
+	FeedStageChanged     = "stageChanged"     // from, to
+	FeedButtonPressed    = "buttonPressed"    // cid, button, level, combo, gold
+	FeedComboStarted     = "comboStarted"     // cid, button
+	FeedPlayerHit        = "playerHit"        // cid, hitCount, lostGold, invincible
+	FeedRampageStart     = "rampageStart"     // side (0 for the team), duration
+	FeedRampageEnd       = "rampageEnd"       // side
+	FeedLaserSpawned     = "laserSpawned"     // x, y, cid of the chased player
+	FeedCountdownStarted = "countdownStarted" // remain
+)
+
+// feed sends a matchEvent to the ingame screens right when it happens, so
+// they can play animations and sounds without diffing updateMatch. Every
+// event has matchID, type and t, the elasped seconds of the match.
+func (m *Match) feed(kind string, d map[string]interface{}) {
+	if d == nil {
+		d = make(map[string]interface{})
+	}
+	d["matchID"] = m.ID
+	d["type"] = kind
+	d["t"] = m.Elasped
+	m.srv.sendMsgs("matchEvent", d, InboxAddressTypeIngameDevice)
+}
+
+func (m *Match) feedStage(from string, to string) {
+	m.feed(FeedStageChanged, map[string]interface{}{"from": from, "to": to})
+	if from == "ongoing-rampage" && to != stagePaused {
+		m.feed(FeedRampageEnd, map[string]interface{}{"side": 0})
+	}
+	// a match stopped while paused in a rampage leaves paused for stop
+	if from == stagePaused && to != m.PausedStage && m.PausedStage == "ongoing-rampage" {
+		m.feed(FeedRampageEnd, map[string]interface{}{"side": 0})
+	}
+	if to == "after" || to == "stop" {
+		for _, side := range m.Sides {
+			if side.RampageTime > 0 {
+				m.feed(FeedRampageEnd, map[string]interface{}{"side": side.Index})
+			}
+		}
+	}
+	switch to {
+	case "ongoing-rampage":
+		if from != stagePaused {
+			m.feed(FeedRampageStart, map[string]interface{}{"side": 0, "duration": m.RampageTime})
+		}
+	case "ongoing-countdown":
+		if from != stagePaused {
+			m.feed(FeedCountdownStarted, map[string]interface{}{"remain": m.TotalTime})
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package core
 
 import (
+	"fmt"
 	"github.com/BurntSushi/toml"
 	"log"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -56,10 +57,15 @@ type RenderInfo struct {
 	PlayerSpeed   float64
 }
 
+const (
+	warmupLargeLaserNum = 10
+	warmupSmallLaserNum = 5
+)
+
 type WarmupLaser struct {
 	Time  int
-	Large [10]int
-	Small [5]int
+	Large []int
+	Small []int
 }
 
 type WarmupInfo struct {
@@ -73,7 +79,71 @@ type LocationTransfer struct {
 	To   int
 }
 
+// GameProfile overlays MatchOptions for one kind of session, e.g. a kids
+// session with slower lasers and no gold punishment. Fields left out in
+// cfg.toml keep the values of the normal game.
+type GameProfile struct {
+	LaserSpeed       *float64    `toml:"laserSpeed"`
+	LaserAppearTime  *float64    `toml:"laserAppearTime"`
+	Mode1TotalTime   *float64    `toml:"mode1TotalTime"`
+	Mode1TouchPunish *[]int      `toml:"mode1TouchPunish"`
+	Mode2TouchPunish *[]int      `toml:"mode2TouchPunish"`
+	RampageTime      *[2]float64 `toml:"rampageTime"`
+	// a profile's laserStrategy is used for every mode unless the profile
+	// also has laserStrategies
+	LaserStrategy   *string            `toml:"laserStrategy"`
+	LaserStrategies *map[string]string `toml:"laserStrategies"`
+}
+
+func (p *GameProfile) apply(m *MatchOptions) {
+	if p.LaserSpeed != nil {
+		m.LaserSpeed = *p.LaserSpeed
+	}
+	if p.LaserAppearTime != nil {
+		m.LaserAppearTime = *p.LaserAppearTime
+	}
+	if p.Mode1TotalTime != nil {
+		m.Mode1TotalTime = *p.Mode1TotalTime
+	}
+	if p.Mode1TouchPunish != nil {
+		m.Mode1TouchPunish = *p.Mode1TouchPunish
+	}
+	if p.Mode2TouchPunish != nil {
+		m.Mode2TouchPunish = *p.Mode2TouchPunish
+	}
+	if p.RampageTime != nil {
+		m.RampageTime = *p.RampageTime
+	}
+	if p.LaserStrategy != nil {
+		m.LaserStrategy = *p.LaserStrategy
+		m.LaserStrategies = nil
+	}
+	if p.LaserStrategies != nil {
+		m.LaserStrategies = *p.LaserStrategies
+	}
+}
+
+const (
+	defaultMaxTeamSize = 4
+	defaultPauseLed    = "1"
+	// a cfg.toml from before practice mode
+	defaultPracticeTime    = 120.0
+	defaultPracticeButtons = 12
+	// a cfg.toml from before the position estimate
+	defaultPositionTileSpeed   = 2.0
+	defaultPositionTimeout     = 5.0
+	defaultPositionJumpConfirm = 3
+	// a cfg.toml from before dropout policies
+	defaultDropoutPolicy = DropoutPolicyContinue
+	// t0-t1, t1-t2, t2-t3 and above t3
+	buttonLevelNum = 4
+)
+
+// the name history requests use for matches played without a profile
+const defaultProfileName = "default"
+
 type MatchOptions struct {
+	Profile           string     `json:"profile" toml:"-"`
 	ArenaWidth        int        `json:"arenaWidth"`
 	ArenaHeight       int        `json:"arenaHeight"`
 	ArenaCellSize     int        `json:"arenaCellSize"`
@@ -91,71 +161,108 @@ type MatchOptions struct {
 	TRampage          float64    `json:"tRampage"`
 	GoldBonus         [2]int     `json:"buttonBonus"`
 	TouchPunish       [2]float64 `json:"touchPunish"`
-	Mode2InitGold     [4]int     `json:"mode2InitGold"`
-	Mode2GoldDropRate [4]int     `json:"mode2GoldDropRate"`
+	Mode2InitGold     []int      `json:"mode2InitGold"`
+	Mode2GoldDropRate []int      `json:"mode2GoldDropRate"`
+	MaxTeamSize       int        `json:"maxTeamSize"`
 	MaxEnergy         float64    `json:"maxEnergy"`
 	Mode1TotalTime    float64    `json:"mode1TotalTime"`
 	Mode1CountDown    float64    `json:"mode1CountDown"`
 	WallRects         []Rect     `json:"walls"`
 	Buttons           []*Button  `json:"buttons"`
 
-	PlayerSpeed           float64            `json:"-"`
-	Walls                 [][]int            `json:"-"`
-	EnergyBonus           [4][4]float64      `json:"-"`
-	InitButtonNum         [4]int             `json:"-"`
-	ButtonHideTime        [2]float64         `json:"-"`
-	RampageTime           [2]float64         `json:"-"`
-	FirstComboInterval    [4]float64         `json:"-"`
-	ComboInterval         [4]float64         `json:"-"`
-	FirstComboExtra       float64            `json:"-"`
-	ComboExtra            float64            `json:"-"`
-	LaserSpeed            float64            `json:"-"`
-	LaserSpeedup          [4]float64         `json:"-"`
-	EnergySpeedup         float64            `json:"-"`
-	LaserAppearTime       float64            `json:"-"`
-	LaserPauseTime        float64            `json:"-"`
-	TileAdjacency         map[int][]int      `json:"-"`
-	PlayerInvincibleTime  float64            `json:"-"`
-	Mode1TouchPunish      [4]int             `json:"-"`
-	Mode2TouchPunish      [4]int             `json:"-"`
-	Mode2GoldDropInterval float64            `json:"-"`
-	MainArduino           []string           `json:"-"`
-	SubArduino            []string           `json:"-"`
-	MusicArduino          []string           `json:"-"`
-	DoorArduino           []string           `json:"-"`
-	MainArduinoInfo       []MainArduino      `json:"-"`
-	UploadTime            int                `json:"-"`
-	HeartbeatTime         int                `json:"-"`
-	SubUploadTime         int                `json:"-"`
-	SubHeartbeatTime      int                `json:"-"`
-	CatchMode             int                `json:"-"`
-	CatchLaserNum         int                `json:"-"`
-	WarmupButtonInterval  float64            `json:"-"`
-	WarmupLasers          []WarmupLaser      `json:"-"`
-	BgIdle                string             `json:"-"`
-	BgWarmup              [2]string          `json:"-"`
-	BgNormal              [2]string          `json:"-"`
-	BgHigh                [2]string          `json:"-"`
-	BgFull                [2]string          `json:"-"`
-	BgRampage             [2]string          `json:"-"`
-	BgCountdown           [2]string          `json:"-"`
-	BgLeave               [2]string          `json:"-"`
-	GoldRank              [4][4]int          `json:"-"`
-	GoldTeamRank          [4][4]int          `json:"-"`
-	SurvivalRank          [4][4]int          `json:"-"`
-	SurvivalTeamRank      [4][4]int          `json:"-"`
-	LocationTransfers     []LocationTransfer `json:"-"`
+	PlayerSpeed           float64                 `json:"-"`
+	Walls                 [][]int                 `json:"-"`
+	EnergyBonus           [][]float64             `json:"-"`
+	InitButtonNum         []int                   `json:"-"`
+	ButtonHideTime        [2]float64              `json:"-"`
+	RampageTime           [2]float64              `json:"-"`
+	FirstComboInterval    []float64               `json:"-"`
+	ComboInterval         []float64               `json:"-"`
+	FirstComboExtra       float64                 `json:"-"`
+	ComboExtra            float64                 `json:"-"`
+	LaserSpeed            float64                 `json:"-"`
+	LaserSpeedup          []float64               `json:"-"`
+	EnergySpeedup         float64                 `json:"-"`
+	LaserAppearTime       float64                 `json:"-"`
+	LaserPauseTime        float64                 `json:"-"`
+	LaserStrategy         string                  `json:"-"`
+	LaserStrategies       map[string]string       `json:"-"` // by mode name
+	TileAdjacency         map[int][]int           `json:"-"`
+	PlayerInvincibleTime  float64                 `json:"-"`
+	Mode1TouchPunish      []int                   `json:"-"`
+	Mode2TouchPunish      []int                   `json:"-"`
+	Mode2GoldDropInterval float64                 `json:"-"`
+	MainArduino           []string                `json:"-"`
+	SubArduino            []string                `json:"-"`
+	MusicArduino          []string                `json:"-"`
+	DoorArduino           []string                `json:"-"`
+	MainArduinoInfo       []MainArduino           `json:"-"`
+	UploadTime            int                     `json:"-"`
+	HeartbeatTime         int                     `json:"-"`
+	SubUploadTime         int                     `json:"-"`
+	SubHeartbeatTime      int                     `json:"-"`
+	CatchMode             int                     `json:"-"`
+	CatchLaserNum         int                     `json:"-"`
+	CatchMinConfidence    float64                 `json:"-"`
+	PositionTileSpeed     float64                 `json:"-"`
+	PositionJumpConfirm   int                     `json:"-"`
+	PositionTimeout       float64                 `json:"-"`
+	DropoutGrace          float64                 `json:"-"`
+	DropoutPolicy         string                  `json:"-"`
+	WarmupButtonInterval  float64                 `json:"-"`
+	WarmupLasers          []WarmupLaser           `json:"-"`
+	BgIdle                string                  `json:"-"`
+	BgWarmup              [2]string               `json:"-"`
+	BgNormal              [2]string               `json:"-"`
+	BgHigh                [2]string               `json:"-"`
+	BgFull                [2]string               `json:"-"`
+	BgRampage             [2]string               `json:"-"`
+	BgCountdown           [2]string               `json:"-"`
+	BgLeave               [2]string               `json:"-"`
+	BgPause               string                  `json:"-"`
+	PauseLed              string                  `json:"-"`
+	GoldRank              [][]int                 `json:"-"`
+	GoldTeamRank          [][]int                 `json:"-"`
+	SurvivalRank          [][]int                 `json:"-"`
+	SurvivalTeamRank      [][]int                 `json:"-"`
+	LocationTransfers     []LocationTransfer      `json:"-"`
+	Profiles              map[string]*GameProfile `json:"-"`
+	PowerUps              PowerUpOptions          `json:"-"`
+	TimelineInterval      float64                 `json:"-"`
+	Scoring               ScoringOptions          `json:"-"`
+	PracticeTime          float64                 `json:"-"`
+	PracticeButtons       int                     `json:"-"`
+	PracticePath          []string                `json:"-"`
+}
+
+func (m *MatchOptions) ProfileNames() []string {
+	names := make([]string, 0, len(m.Profiles))
+	for name := range m.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-type ScoreInfo [4]map[string]interface{}
-
-var opt = DefaultMatchOptions()
-
-func GetOptions() *MatchOptions {
-	return opt
+// WithProfile returns the options a match of profile name plays with, the
+// empty name is the normal game and returns m itself
+func (m *MatchOptions) WithProfile(name string) (*MatchOptions, error) {
+	if name == "" {
+		return m, nil
+	}
+	p, ok := m.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %v", name)
+	}
+	o := *m
+	o.Profile = name
+	p.apply(&o)
+	return &o, nil
 }
 
-func GetScoreInfo() ScoreInfo {
+type ScoreInfo [4]map[string]interface{}
+
+func (opt *MatchOptions) ScoreInfo() ScoreInfo {
 	return [4]map[string]interface{}{
 		map[string]interface{}{
 			"time":   strconv.FormatFloat(opt.T1, 'f', -1, 64),
@@ -176,16 +283,49 @@ func GetScoreInfo() ScoreInfo {
 	}
 }
 
-func DefaultMatchOptions() *MatchOptions {
+// LoadMatchOptions reads and validates cfg.toml and warmup.toml, the returned
+// error is a ValidationErrors if the files parse but hold invalid values
+func LoadMatchOptions(cfgPath string, warmupPath string) (*MatchOptions, error) {
 	var opt MatchOptions
-	if _, err := toml.DecodeFile("cfg.toml", &opt); err != nil {
-		log.Printf("parse cfg.toml error:%v\n", err.Error())
-		os.Exit(1)
+	if _, err := toml.DecodeFile(cfgPath, &opt); err != nil {
+		return nil, fmt.Errorf("parse %v error:%v", cfgPath, err.Error())
 	}
 	var warmupInfo WarmupInfo
-	if _, err := toml.DecodeFile("warmup.toml", &warmupInfo); err != nil {
-		log.Printf("parse warmup.toml error:%v\n", err.Error())
-		os.Exit(1)
+	if _, err := toml.DecodeFile(warmupPath, &warmupInfo); err != nil {
+		return nil, fmt.Errorf("parse %v error:%v", warmupPath, err.Error())
+	}
+	if opt.MaxTeamSize == 0 {
+		opt.MaxTeamSize = defaultMaxTeamSize
+	}
+	if opt.BgPause == "" {
+		opt.BgPause = opt.BgIdle
+	}
+	if opt.PauseLed == "" {
+		opt.PauseLed = defaultPauseLed
+	}
+	if opt.PracticeTime == 0 {
+		opt.PracticeTime = defaultPracticeTime
+	}
+	if opt.PracticeButtons == 0 {
+		opt.PracticeButtons = defaultPracticeButtons
+	}
+	if opt.PositionTileSpeed == 0 {
+		opt.PositionTileSpeed = defaultPositionTileSpeed
+	}
+	if opt.PositionTimeout == 0 {
+		opt.PositionTimeout = defaultPositionTimeout
+	}
+	if opt.PositionJumpConfirm == 0 {
+		opt.PositionJumpConfirm = defaultPositionJumpConfirm
+	}
+	if opt.DropoutPolicy == "" {
+		opt.DropoutPolicy = defaultDropoutPolicy
+	}
+	var errs ValidationErrors
+	errs.merge(cfgPath+":", opt.Validate())
+	errs.merge(warmupPath+":", warmupInfo.Validate())
+	if len(errs) > 0 {
+		return nil, errs
 	}
 	opt.Warmup = float64(warmupInfo.WarmupTime) / 1000
 	opt.WarmupButtonInterval = float64(warmupInfo.WarmupButtonInterval)
@@ -194,7 +334,7 @@ func DefaultMatchOptions() *MatchOptions {
 	opt.buildWallRects()
 	opt.buildButtons()
 	opt.buildAdjacency()
-	return &opt
+	return &opt, nil
 }
 
 func (m *MatchOptions) buildMainArduinoInfo() {
@@ -205,17 +345,48 @@ func (m *MatchOptions) buildMainArduinoInfo() {
 	}
 }
 
+// arduinoInfoFromID never fails, a malformed id gives an info at tile (0, 0)
+// without lasers. ids from config are checked by parseArduinoID beforehand.
 func arduinoInfoFromID(id string) *MainArduino {
+	info, err := parseArduinoID(id)
+	if err != nil {
+		log.Printf("warning:%v\n", err.Error())
+		return &MainArduino{ID: id}
+	}
+	return info
+}
+
+// main arduino id format is M-x-y-dir-type-laserNum-laserDir, e.g. M-1-2-2-A-10-R
+func parseArduinoID(id string) (*MainArduino, error) {
+	li := strings.Split(id, "-")
+	if len(li) != 7 || li[0] != "M" {
+		return nil, fmt.Errorf("%v should be M-x-y-dir-type-laserNum-laserDir", id)
+	}
 	info := MainArduino{}
 	info.ID = id
-	li := strings.Split(id, "-")
-	info.X, _ = strconv.Atoi(li[1])
-	info.Y, _ = strconv.Atoi(li[2])
-	info.Dir, _ = strconv.Atoi(li[3])
+	var errX, errY, errDir, errNum error
+	info.X, errX = strconv.Atoi(li[1])
+	info.Y, errY = strconv.Atoi(li[2])
+	info.Dir, errDir = strconv.Atoi(li[3])
 	info.Type = li[4]
-	info.LaserNum, _ = strconv.Atoi(li[5])
+	info.LaserNum, errNum = strconv.Atoi(li[5])
 	info.LaserDir = li[6]
-	return &info
+	if errX != nil || errY != nil {
+		return nil, fmt.Errorf("%v has invalid position", id)
+	}
+	if errDir != nil || info.Dir < 1 || info.Dir > 4 {
+		return nil, fmt.Errorf("%v has invalid dir, should be 1-4", id)
+	}
+	if info.Type != "A" && info.Type != "B" {
+		return nil, fmt.Errorf("%v has invalid type, should be A or B", id)
+	}
+	if errNum != nil || (info.LaserNum != 5 && info.LaserNum != 10) {
+		return nil, fmt.Errorf("%v has invalid laser number, should be 5 or 10", id)
+	}
+	if info.LaserDir != "L" && info.LaserDir != "R" {
+		return nil, fmt.Errorf("%v has invalid laser dir, should be L or R", id)
+	}
+	return &info, nil
 }
 
 func (m *MatchOptions) buildAdjacency() {
@@ -410,8 +581,14 @@ func (m *MatchOptions) laserSpeed(energy float64, playerCount int) float64 {
 }
 
 func (m *MatchOptions) laserMoveInterval(energy float64, playerCount int) float64 {
-	level := int(energy / m.EnergySpeedup)
-	return m.LaserSpeed - float64(level)*m.LaserSpeedup[playerCount-1]
+	level := m.laserSpeedLevel(energy)
+	return m.LaserSpeed - float64(level)*teamSizeFloat(m.LaserSpeedup, playerCount)
+}
+
+// laserSpeedLevel is the number of laserSpeedup steps lasers moving at energy
+// have taken
+func (m *MatchOptions) laserSpeedLevel(energy float64) int {
+	return int(energy / m.EnergySpeedup)
 }
 
 func (m *MatchOptions) mainArduinosByPos(x int, y int) []string {
@@ -424,24 +601,8 @@ func (m *MatchOptions) mainArduinosByPos(x int, y int) []string {
 	return ret
 }
 
-func (m *MatchOptions) TeamGrade(gold int, elasped float64, teamSize int, mode string) string {
-	if mode == "g" {
-		return m.calcGrade(gold, teamSize, &m.GoldTeamRank)
-	} else {
-		return m.calcGrade(int(elasped*1000), teamSize, &m.SurvivalTeamRank)
-	}
-}
-
-func (m *MatchOptions) PersonGrade(gold int, teamSize int, mode string) string {
-	if mode == "g" {
-		return m.calcGrade(gold, teamSize, &m.GoldRank)
-	} else {
-		return m.calcGrade(gold, teamSize, &m.SurvivalRank)
-	}
-}
-
-func (m *MatchOptions) calcGrade(gold int, teamSize int, data *[4][4]int) string {
-	row := data[teamSize-1]
+func (m *MatchOptions) calcGrade(gold int, teamSize int, data [][]int) string {
+	row := data[teamSizeIndex(len(data), teamSize)]
 	if gold < row[3] {
 		return "D"
 	} else if gold < row[2] {
@@ -464,3 +625,23 @@ func (m *MatchOptions) mainArduinoInfosByPos(intP int) []MainArduino {
 	}
 	return ret
 }
+
+// team size tables hold one entry per team size starting from 1 player,
+// larger teams use the last entry
+func teamSizeIndex(n int, teamSize int) int {
+	if teamSize > n {
+		return n - 1
+	}
+	if teamSize < 1 {
+		return 0
+	}
+	return teamSize - 1
+}
+
+func teamSizeInt(li []int, teamSize int) int {
+	return li[teamSizeIndex(len(li), teamSize)]
+}
+
+func teamSizeFloat(li []float64, teamSize int) float64 {
+	return li[teamSizeIndex(len(li), teamSize)]
+}
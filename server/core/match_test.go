@@ -0,0 +1,143 @@
+package core
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var testConfigPaths = ConfigPaths{"../cfg.toml", "../warmup.toml", "../survey.toml", "../laser.json", "../achievements.toml"}
+
+// newTestSrv is a simulator Srv of the shipped config driven by clock, the
+// match events are dropped
+func newTestSrv(t *testing.T, clock Clock) *Srv {
+	c, err := LoadSrvConfig(testConfigPaths, true)
+	if err != nil {
+		t.Fatalf("load config error:%v", err)
+	}
+	c.Clock = clock
+	s := NewSrv(c)
+	go func() {
+		for range s.mChan {
+		}
+	}()
+	return s
+}
+
+type matchChoices struct {
+	warmupTicks int
+	buttons     []string
+	lasers      []int
+}
+
+// stepBountyMatch steps a bounty match of seed through warmup and ticks more
+// ticks of ongoing, where the lasers have moved
+func stepBountyMatch(t *testing.T, seed int64, ticks int) matchChoices {
+	clock := NewManualClock(time.Unix(1000, 0))
+	s := newTestSrv(t, clock)
+	md := MatchData{Seed: seed}
+	m := NewMatch(s, s.GetOptions(), []string{"1", "2"}, &md, "g", "", true)
+	m.Start()
+	c := matchChoices{}
+	for !m.isOngoing() {
+		if !m.Step() {
+			t.Fatalf("match ended in stage %v", m.Stage)
+		}
+		clock.Advance(matchTickInterval)
+		c.warmupTicks += 1
+	}
+	for k := range m.OnButtons {
+		c.buttons = append(c.buttons, k)
+	}
+	sort.Strings(c.buttons)
+	for i := 0; i < ticks; i++ {
+		if !m.Step() {
+			t.Fatalf("match ended in stage %v", m.Stage)
+		}
+		clock.Advance(matchTickInterval)
+	}
+	for _, l := range m.Lasers {
+		c.lasers = append(c.lasers, l.Tile())
+	}
+	return c
+}
+
+func TestMatchStepIsDeterministic(t *testing.T) {
+	ticks := int(10 * time.Second / matchTickInterval)
+	a := stepBountyMatch(t, 42, ticks)
+	b := stepBountyMatch(t, 42, ticks)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("same seed, different matches:\n%+v\n%+v", a, b)
+	}
+
+	opt := newTestSrv(t, NewManualClock(time.Unix(1000, 0))).GetOptions()
+	if want := int(opt.Warmup*float64(time.Second)/float64(matchTickInterval)) + 1; a.warmupTicks != want {
+		t.Errorf("warmup took %v ticks, want %v", a.warmupTicks, want)
+	}
+	if want := teamSizeInt(opt.InitButtonNum, 2); len(a.buttons) != want {
+		t.Errorf("%v buttons lit, want %v", len(a.buttons), want)
+	}
+	if len(a.lasers) == 0 {
+		t.Errorf("no laser after %v ticks of ongoing", ticks)
+	}
+
+	other := stepBountyMatch(t, 43, ticks)
+	if reflect.DeepEqual(a.buttons, other.buttons) {
+		t.Errorf("seeds 42 and 43 lit the same buttons %v", a.buttons)
+	}
+}
+
+func TestDropoutWhilePaused(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	s := newTestSrv(t, clock)
+	md := MatchData{Seed: 42}
+	m := NewMatch(s, s.GetOptions(), []string{"1", "2"}, &md, "g", "", true)
+	m.opt.DropoutPolicy = DropoutPolicyPause
+	m.Start()
+	for !m.isOngoing() {
+		m.Step()
+		clock.Advance(matchTickInterval)
+	}
+	m.pause()
+	m.playerOffline("1")
+	if _, ok := m.dropouts["1"]; !ok {
+		t.Fatalf("no dropout for a wearable lost while paused")
+	}
+	m.resume()
+	for i := 0; m.Stage != stagePaused; i++ {
+		if i > int((m.opt.DropoutGrace+1)*float64(time.Second/matchTickInterval)) {
+			t.Fatalf("match not paused after the grace period, stage %v", m.Stage)
+		}
+		m.Step()
+		clock.Advance(matchTickInterval)
+	}
+	m.resume()
+	if p := m.getPlayer("1"); p.dropoutPolicy != dropoutResumePolicy {
+		t.Errorf("dropout policy after resume %q, want %q", p.dropoutPolicy, dropoutResumePolicy)
+	}
+}
+
+func TestBlockedLineNeverCatches(t *testing.T) {
+	s := newTestSrv(t, NewManualClock(time.Unix(1000, 0)))
+	md := MatchData{Seed: 42}
+	m := NewMatch(s, s.GetOptions(), []string{"1"}, &md, "g", "", true)
+	opt := m.opt
+	tile := 0
+	next := opt.Conv(opt.TileAdjacency[opt.Conv(tile)][0])
+	player := m.Member[0]
+	m.fixPosition(player, next, confidenceJump)
+
+	m.observeBlocked(tile)
+	if got := opt.TilePosToInt(player.tilePos); got != tile {
+		t.Fatalf("player on %v, want moved to the blocked tile %v", got, tile)
+	}
+	m.observeBlocked(tile)
+	if m.catchOnTile(tile, make(map[int]bool)) {
+		t.Errorf("player caught on a tile inferred from blocked lines")
+	}
+	m.fixPosition(player, tile, confidenceWearable)
+	if !m.catchOnTile(tile, make(map[int]bool)) {
+		t.Errorf("player not caught once the wearable reports the tile")
+	}
+}
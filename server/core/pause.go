@@ -0,0 +1,120 @@
+package core
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+var _ = log.Printf
+
+const stagePaused = "paused"
+
+// pause freezes the match where it is: lasers off, buttons disabled and no
+// tick runs until resume, so every timer keeps its value
+func (m *Match) pause() {
+	if !m.isWarmup() && !m.isOngoing() {
+		m.srv.sends(NewErrorInboxMessage("比赛当前无法暂停"), InboxAddressTypeAdminDevice)
+		return
+	}
+	m.PausedStage = m.Stage
+	m.currentPause = &PauseData{}
+	m.currentPause.Stage = m.Stage
+	m.currentPause.At = m.Elasped
+	m.currentPause.StartedAt = m.clock.Now()
+	for _, laser := range m.Lasers {
+		laser.Suspend()
+	}
+	msg := NewInboxMessage()
+	msg.SetCmd("btn_ctrl")
+	msg.Set("useful", "0")
+	msg.Set("mode", m.mode.Effects().ButtonMode)
+	msg.Set("stage", "0")
+	m.srv.sends(msg, InboxAddressTypeMainArduinoDevice)
+	m.srv.bgControl(m.opt.BgPause)
+	m.srv.ledControl(3, m.opt.PauseLed)
+	m.switchStage(stagePaused)
+}
+
+func (m *Match) resume() {
+	if m.Stage != stagePaused {
+		return
+	}
+	m.endPause()
+	m.switchStage(m.PausedStage)
+	m.PausedStage = ""
+	m.restoreStageEffects()
+	m.restoreButtons()
+	m.resumeDropouts()
+	for _, laser := range m.Lasers {
+		laser.Resume()
+	}
+}
+
+// endPause saves the running pause period, it is also called when a paused
+// match is stopped
+func (m *Match) endPause() {
+	if m.currentPause == nil {
+		return
+	}
+	m.currentPause.Duration = m.clock.Now().Sub(m.currentPause.StartedAt).Seconds()
+	m.matchData.Pauses = append(m.matchData.Pauses, *m.currentPause)
+	m.currentPause = nil
+}
+
+// restoreStageEffects shows the music and leds of the current stage again
+// without running the rest of setStage
+func (m *Match) restoreStageEffects() {
+	fx := m.mode.Effects()
+	switch {
+	case m.isWarmup():
+		m.srv.bgControl(m.opt.BgWarmup[m.modeIndex()])
+		m.srv.ledControl(3, "0", "1", "2", "3")
+	case strings.HasPrefix(m.Stage, "ongoing-low"):
+		level, _ := strconv.Atoi(strings.Split(m.Stage, "-")[2])
+		m.srv.lightControl("1")
+		m.srv.bgControl(m.opt.BgNormal[m.modeIndex()])
+		m.srv.ledControl(3, strconv.Itoa(level+fx.LowLedBase))
+	case m.Stage == "ongoing-high":
+		m.srv.lightControl("2")
+		m.srv.bgControl(m.opt.BgHigh[m.modeIndex()])
+		m.srv.ledControl(3, fx.HighLed)
+	case m.Stage == "ongoing-full":
+		m.srv.bgControl(m.opt.BgFull[m.modeIndex()])
+		m.srv.ledControl(3, fx.FullLed)
+	case m.Stage == "ongoing-rampage":
+		m.srv.lightControl("0")
+		m.srv.bgControl(m.opt.BgRampage[m.modeIndex()])
+		offButtons := make(map[string]bool)
+		for _, id := range m.offButtons {
+			offButtons[id] = true
+		}
+		m.srv.ledRampageEffect(offButtons)
+	case m.Stage == "ongoing-countdown":
+		m.srv.bgControl(m.opt.BgCountdown[m.modeIndex()])
+		m.srv.ledControl(1, "47")
+		m.srv.ledControl(2, "46")
+	}
+}
+
+func (m *Match) restoreButtons() {
+	if m.isWarmup() {
+		for k, v := range m.warmupCellButtonStatus {
+			if v {
+				m.buttonControl(k, true)
+			}
+		}
+		return
+	}
+	if m.OnButtons == nil {
+		return
+	}
+	switch {
+	case m.Stage == "ongoing-rampage":
+		m.setButtonEffect("2", false)
+	case strings.HasPrefix(m.Stage, "ongoing-low"):
+		m.setButtonEffect("0", true)
+	default:
+		m.setButtonEffect("1", true)
+	}
+}
@@ -2,7 +2,6 @@ package core
 
 import (
 	"log"
-	"time"
 )
 
 var _ = log.Printf
@@ -24,13 +23,20 @@ type Player struct {
 	ControllerID   string  `json:"cid"`
 	DisplayPos     RP      `json:"displayPos"`
 	Offline        int     `json:"offline"`
+	Side           int     `json:"side"` // 1 or 2 in versus matches, 0 otherwise
+	PowerUps       int     `json:"powerUps"`
+	MaxCombo       int     `json:"maxCombo"`   // the longest combo streak
+	Confidence     float64 `json:"confidence"` // 0 to 1, how sure the match is of the tile of the player
 
 	moving      bool
 	lastButton  string
-	lastHitTime time.Time
+	lastHitAt   float64 // Elasped of the match at the last scored button, -1 before it
 	isSimulator bool
 	tilePos     P
 	status      string
+	estimate    positionEstimate
+	// the dropout policy applied to the laser while the wearable is offline
+	dropoutPolicy string
 }
 
 func NewPlayer(cid string, isSimulator bool) *Player {
@@ -39,10 +45,14 @@ func NewPlayer(cid string, isSimulator bool) *Player {
 	p.Direction = "up"
 	p.ControllerID = cid
 	p.LevelData = [4]int{0, 0, 0, 0}
-	p.lastHitTime = time.Unix(0, 0)
+	p.lastHitAt = -1
 	p.isSimulator = isSimulator
 	p.status = ""
 	p.Offline = 0
+	p.estimate = newPositionEstimate()
+	if isSimulator {
+		p.Confidence = 1
+	}
 	return &p
 }
 
@@ -55,9 +65,8 @@ func (p *Player) setOnline() {
 	p.Offline = 0
 }
 
-func (p *Player) updateLoc(loc int) {
-	opt := GetOptions()
-	loc = opt.TransferWearableLocation(loc) - 1
+// setTile moves the player to loc, a TilePosToInt index
+func (p *Player) setTile(loc int, opt *MatchOptions) {
 	if tp, valid := opt.TryIntToTile(loc); valid {
 		p.tilePos = tp
 		p.Pos = opt.RealPosition(p.tilePos)
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"log"
+	"math"
+)
+
+var _ = log.Printf
+
+// confidence a piece of evidence gives the tile of a player
+const (
+	confidenceButton   = 1.0 // the player pressed a button of the tile
+	confidenceBlocked  = 0.9 // a line of the tile is blocked
+	confidenceWearable = 0.8
+	confidenceJump     = 0.5 // the wearable kept reporting a tile out of reach
+	confidenceMoved    = 0.6 // a blocked line next to a player nobody else could block
+	// a wearable report out of reach makes the tile that much less certain
+	confidenceRejected = 0.2
+)
+
+// positionEstimate fuses the wearable reports, button presses and blocked
+// receivers into Player.tilePos. A wearable report the player could not have
+// walked to since the last fix is kept as a candidate and only taken after
+// positionJumpConfirm reports in a row.
+type positionEstimate struct {
+	tile       int     // TilePosToInt of tilePos, -1 before the first fix
+	sinceFix   float64 // seconds since the last evidence
+	candidate  int
+	candidateN int
+	// the tile was only inferred from a blocked line next to the player,
+	// such a tile never gets the player caught
+	inferred bool
+}
+
+func newPositionEstimate() positionEstimate {
+	return positionEstimate{tile: -1, candidate: -1}
+}
+
+// tileDistance is the number of steps between tiles a and b given as
+// TilePosToInt, -1 when b can't be reached from a
+func (m *MatchOptions) tileDistance(a int, b int) int {
+	from, to := m.Conv(a), m.Conv(b)
+	if from == to {
+		return 0
+	}
+	dist := map[int]int{from: 0}
+	queue := []int{from}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		for _, i := range m.TileAdjacency[t] {
+			if _, ok := dist[i]; ok {
+				continue
+			}
+			if i == to {
+				return dist[t] + 1
+			}
+			dist[i] = dist[t] + 1
+			queue = append(queue, i)
+		}
+	}
+	return -1
+}
+
+// reachable is how many steps the player may have walked since the last fix
+func (m *Match) reachable(player *Player) int {
+	return 1 + int(player.estimate.sinceFix*m.opt.PositionTileSpeed)
+}
+
+func (m *Match) fixPosition(player *Player, tile int, confidence float64) {
+	e := &player.estimate
+	if e.tile == tile {
+		player.Confidence = math.Max(player.Confidence, confidence)
+	} else {
+		player.Confidence = confidence
+		player.setTile(tile, m.opt)
+	}
+	e.tile = tile
+	e.sinceFix = 0
+	e.candidate = -1
+	e.candidateN = 0
+	e.inferred = false
+}
+
+// observeWearable takes loc reported by the wearable of player
+func (m *Match) observeWearable(player *Player, loc int) {
+	loc = m.opt.TransferWearableLocation(loc) - 1
+	if _, valid := m.opt.TryIntToTile(loc); !valid {
+		return
+	}
+	e := &player.estimate
+	if e.tile < 0 {
+		m.fixPosition(player, loc, confidenceWearable)
+		return
+	}
+	if d := m.opt.tileDistance(e.tile, loc); d >= 0 && d <= m.reachable(player) {
+		m.fixPosition(player, loc, confidenceWearable)
+		return
+	}
+	if e.candidate == loc {
+		e.candidateN += 1
+	} else {
+		e.candidate = loc
+		e.candidateN = 1
+	}
+	if e.candidateN >= m.opt.PositionJumpConfirm {
+		log.Printf("player %v jumped from %v to %v\n", player.ControllerID, e.tile, loc)
+		m.fixPosition(player, loc, confidenceJump)
+		return
+	}
+	player.Confidence = math.Max(player.Confidence-confidenceRejected, 0)
+}
+
+// observeButton is a press of a button on tile, the player who pressed it is
+// on the tile or, when the wearable lags behind, next to it. It returns nil
+// when nobody is near enough.
+func (m *Match) observeButton(tile int) *Player {
+	var found *Player
+	best := -1
+	for _, player := range m.Member {
+		if player.estimate.tile < 0 {
+			continue
+		}
+		d := m.opt.tileDistance(player.estimate.tile, tile)
+		if d < 0 || d > 1 {
+			continue
+		}
+		if found == nil || d < best || d == best && player.Confidence > found.Confidence {
+			found = player
+			best = d
+		}
+	}
+	if found != nil {
+		m.fixPosition(found, tile, confidenceButton)
+	}
+	return found
+}
+
+// observeBlocked is a blocked line on tile, it confirms the players on it or
+// moves the least certain player next to it
+func (m *Match) observeBlocked(tile int) {
+	var nearest *Player
+	confirmed := false
+	for _, player := range m.Member {
+		if player.estimate.tile < 0 || player.Offline > 0 {
+			continue
+		}
+		switch m.opt.tileDistance(player.estimate.tile, tile) {
+		case 0:
+			// a blocked line confirms an inferred tile but doesn't make it
+			// one to be caught on
+			inferred := player.estimate.inferred
+			m.fixPosition(player, tile, confidenceBlocked)
+			player.estimate.inferred = inferred
+			confirmed = true
+		case 1:
+			if nearest == nil || player.Confidence < nearest.Confidence {
+				nearest = player
+			}
+		}
+	}
+	if !confirmed && nearest != nil && nearest.Confidence < confidenceMoved {
+		m.fixPosition(nearest, tile, confidenceMoved)
+		nearest.estimate.inferred = true
+	}
+}
+
+// decayPosition lowers the confidence of a player without evidence, it is 0
+// after positionTimeout seconds
+func (m *Match) decayPosition(player *Player, sec float64) {
+	player.estimate.sinceFix += sec
+	player.Confidence = math.Max(player.Confidence-sec/m.opt.PositionTimeout, 0)
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"log"
+)
+
+var _ = log.Printf
+
+const (
+	PowerUpFreeze = "freeze" // pause every laser
+	PowerUpDouble = "double" // double the gold of buttons
+	PowerUpShield = "shield" // make the player invincible
+	PowerUpReveal = "reveal" // show every hidden button at once
+)
+
+// PowerUp is one kind of special button. Duration is not used by reveal.
+type PowerUp struct {
+	Weight   int     `toml:"weight"`
+	Duration float64 `toml:"duration"`
+	Led      string  `toml:"led"`
+}
+
+// PowerUpOptions is the [powerUps] section of cfg.toml. When a hidden
+// button lights up again it becomes a power-up with probability Chance, the
+// kind is picked by weight.
+type PowerUpOptions struct {
+	Chance float64 `toml:"chance"`
+	Freeze PowerUp `toml:"freeze"`
+	Double PowerUp `toml:"double"`
+	Shield PowerUp `toml:"shield"`
+	Reveal PowerUp `toml:"reveal"`
+}
+
+// kinds lists the power-ups in a fixed order so picking one by weight only
+// depends on the match seed
+func (o *PowerUpOptions) kinds() []string {
+	return []string{PowerUpFreeze, PowerUpDouble, PowerUpShield, PowerUpReveal}
+}
+
+func (o *PowerUpOptions) get(kind string) *PowerUp {
+	switch kind {
+	case PowerUpFreeze:
+		return &o.Freeze
+	case PowerUpDouble:
+		return &o.Double
+	case PowerUpShield:
+		return &o.Shield
+	case PowerUpReveal:
+		return &o.Reveal
+	}
+	return nil
+}
+
+func (o *PowerUpOptions) totalWeight() int {
+	total := 0
+	for _, kind := range o.kinds() {
+		total += o.get(kind).Weight
+	}
+	return total
+}
+
+// maybeSpawnPowerUp turns the button that just lit up into a power-up
+func (m *Match) maybeSpawnPowerUp(btn string) {
+	opt := &m.opt.PowerUps
+	total := opt.totalWeight()
+	if opt.Chance <= 0 || total <= 0 {
+		return
+	}
+	if m.rand.Float64() >= opt.Chance {
+		return
+	}
+	n := m.rand.Intn(total)
+	for _, kind := range opt.kinds() {
+		n -= opt.get(kind).Weight
+		if n < 0 {
+			m.PowerUps[btn] = kind
+			addr := InboxAddress{InboxAddressTypeMainArduinoDevice, btn}
+			m.srv.ledControlByAddresses(opt.get(kind).Led, []InboxAddress{addr})
+			return
+		}
+	}
+}
+
+// triggerPowerUp is called after player pressed btn
+func (m *Match) triggerPowerUp(btn string, player *Player) {
+	kind, ok := m.PowerUps[btn]
+	if !ok {
+		return
+	}
+	delete(m.PowerUps, btn)
+	p := m.opt.PowerUps.get(kind)
+	switch kind {
+	case PowerUpFreeze:
+		for _, laser := range m.Lasers {
+			laser.Pause(p.Duration)
+		}
+	case PowerUpDouble:
+		m.DoubleGold[player.Side] = p.Duration
+	case PowerUpShield:
+		player.InvincibleTime += p.Duration
+	case PowerUpReveal:
+		for _, t := range m.hiddenButtons {
+			*t = 0
+		}
+	}
+	player.PowerUps += 1
+	log.Printf("power-up %v triggered by %v\n", kind, player.ControllerID)
+	d := map[string]interface{}{
+		"matchID":  m.ID,
+		"kind":     kind,
+		"cid":      player.ControllerID,
+		"duration": p.Duration,
+	}
+	m.srv.sendMsgs("powerUp", d, InboxAddressTypeIngameDevice, InboxAddressTypeSimulatorDevice, InboxAddressTypeAdminDevice)
+}
+
+func (m *Match) powerUpTick(sec float64) {
+	for side, t := range m.DoubleGold {
+		if t -= sec; t > 0 {
+			m.DoubleGold[side] = t
+		} else {
+			delete(m.DoubleGold, side)
+		}
+	}
+}
+
+// goldFactor is 2 while the side of player has double gold
+func (m *Match) goldFactor(player *Player) int {
+	if m.DoubleGold[player.Side] > 0 {
+		return 2
+	}
+	return 1
+}
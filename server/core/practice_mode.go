@@ -0,0 +1,211 @@
+package core
+
+import (
+	"log"
+	"math"
+)
+
+var _ = log.Printf
+
+// GuidedMode is a mode without lasers that lights the buttons itself. Match
+// skips the warmup lasers and the random buttons and hands every press, of
+// any level, to ButtonPressed.
+type GuidedMode interface {
+	GameMode
+	// InitButtons is called instead of initButtons when warmup is over
+	InitButtons(m *Match)
+	// ButtonPressed is called for every press of btn, level 0 is too short
+	ButtonPressed(m *Match, p *Player, btn string, level int)
+}
+
+// unsavedMode is implemented by modes whose matches are kept out of the
+// history and the leaderboards
+type unsavedMode interface {
+	Unsaved()
+}
+
+func (m *Match) guided() GuidedMode {
+	g, _ := m.mode.(GuidedMode)
+	return g
+}
+
+// PracticeState is the progress of a practice match, Current is the index
+// in Path of the lit button
+type PracticeState struct {
+	Path    []string        `json:"path"`
+	Current int             `json:"current"`
+	Presses []PracticePress `json:"presses"`
+}
+
+// PracticePress is one press of the lit button, Level is 0 when the button
+// was let go before t1
+type PracticePress struct {
+	Button       string  `json:"button"`
+	ControllerID string  `json:"cid"`
+	Level        int     `json:"level"`
+	At           float64 `json:"at"`
+}
+
+func init() {
+	RegisterGameMode(practiceMode{})
+}
+
+// 练习模式, no lasers, one button after another lights up and the level of
+// every press is shown, so new players learn how long to hold a button
+type practiceMode struct{}
+
+func (practiceMode) Name() string {
+	return "p"
+}
+
+func (practiceMode) Title() string {
+	return "练习"
+}
+
+func (practiceMode) OptionIndex() int {
+	return 0
+}
+
+func (practiceMode) Effects() StageEffects {
+	return StageEffects{ButtonMode: "1", Door: "5", LowLedBase: 5, HighLed: "9", FullLed: "19"}
+}
+
+func (practiceMode) Unsaved() {
+}
+
+func (practiceMode) Init(m *Match) {
+	m.TotalTime = m.opt.PracticeTime
+	m.Practice = &PracticeState{Presses: make([]PracticePress, 0)}
+}
+
+func (practiceMode) Start(m *Match) {
+}
+
+func (practiceMode) Tick(m *Match, sec float64) {
+	m.TotalTime = math.Max(m.TotalTime-sec, 0)
+}
+
+// ConsumeButton scores nothing, presses go to ButtonPressed
+func (practiceMode) ConsumeButton(m *Match, p *Player, level int) {
+}
+
+func (practiceMode) TouchPunish(m *Match, p *Player) {
+}
+
+func (practiceMode) NextStage(m *Match, s string) string {
+	// the path is made when the match leaves warmup
+	if m.TotalTime <= 0 || !m.isWarmup() && m.Practice.Current >= len(m.Practice.Path) {
+		return "after"
+	}
+	return s
+}
+
+func (practiceMode) TeamGrade(m *Match) string {
+	return ""
+}
+
+func (practiceMode) PersonGrade(m *Match, p *Player) string {
+	return ""
+}
+
+func (practiceMode) InitButtons(m *Match) {
+	path := m.practicePath()
+	m.Practice.Path = path
+	m.OnButtons = make(map[string]bool)
+	m.offButtons = make([]string, 0, len(m.opt.Buttons))
+	m.hiddenButtons = make(map[string]*float64)
+	for _, btn := range m.opt.Buttons {
+		if len(path) > 0 && btn.Id == path[0] {
+			m.OnButtons[btn.Id] = true
+		} else {
+			m.offButtons = append(m.offButtons, btn.Id)
+		}
+	}
+	if !m.isSimulator {
+		m.setButtonEffect("0", true)
+	}
+}
+
+// ButtonPressed moves on to the next button of the path once the lit one
+// is pressed long enough, a press that is too short keeps it lit
+func (practiceMode) ButtonPressed(m *Match, p *Player, btn string, level int) {
+	st := m.Practice
+	if st.Current >= len(st.Path) || st.Path[st.Current] != btn {
+		return
+	}
+	press := PracticePress{btn, p.ControllerID, level, m.Elasped}
+	st.Presses = append(st.Presses, press)
+	d := map[string]interface{}{
+		"matchID": m.ID,
+		"index":   st.Current,
+		"total":   len(st.Path),
+		"press":   press,
+	}
+	m.srv.sendMsgs("practicePress", d, InboxAddressTypeIngameDevice, InboxAddressTypeSimulatorDevice, InboxAddressTypeAdminDevice)
+	if level == 0 {
+		m.setSingleButtonEffect(btn)
+		return
+	}
+	delete(m.OnButtons, btn)
+	m.offButtons = append(m.offButtons, btn)
+	m.practiceButtonOff(btn)
+	st.Current += 1
+	if st.Current < len(st.Path) {
+		next := st.Path[st.Current]
+		for i, id := range m.offButtons {
+			if id == next {
+				m.offButtons = append(m.offButtons[:i], m.offButtons[i+1:]...)
+				break
+			}
+		}
+		m.OnButtons[next] = true
+		m.setSingleButtonEffect(next)
+	}
+}
+
+func (m *Match) practiceButtonOff(btn string) {
+	msg := NewInboxMessage()
+	msg.SetCmd("btn_ctrl")
+	msg.Set("useful", "0")
+	msg.Set("mode", m.mode.Effects().ButtonMode)
+	msg.Set("stage", "0")
+	m.srv.sendToOne(msg, InboxAddress{InboxAddressTypeMainArduinoDevice, btn})
+}
+
+// practicePath is practicePath of the options, without it a walk of
+// practiceButtons buttons from the entrance where every button is on the
+// tile next to the previous one
+func (m *Match) practicePath() []string {
+	opt := m.opt
+	if len(opt.PracticePath) > 0 {
+		return append([]string{}, opt.PracticePath...)
+	}
+	byTile := make(map[int][]string)
+	for _, btn := range opt.Buttons {
+		info := arduinoInfoFromID(btn.Id)
+		t := opt.TilePosToInt(P{info.X - 1, info.Y - 1})
+		byTile[t] = append(byTile[t], btn.Id)
+	}
+	path := make([]string, 0, opt.PracticeButtons)
+	tile := opt.TilePosToInt(opt.ArenaEntrance)
+	last := ""
+	for len(path) < opt.PracticeButtons && len(opt.Buttons) > 1 {
+		candidates := make([]string, 0)
+		for _, i := range opt.TileAdjacency[opt.Conv(tile)] {
+			candidates = append(candidates, byTile[opt.Conv(i)]...)
+		}
+		if len(candidates) == 0 {
+			for _, btn := range opt.Buttons {
+				if btn.Id != last {
+					candidates = append(candidates, btn.Id)
+				}
+			}
+		}
+		next := candidates[m.rand.Intn(len(candidates))]
+		info := arduinoInfoFromID(next)
+		tile = opt.TilePosToInt(P{info.X - 1, info.Y - 1})
+		path = append(path, next)
+		last = next
+	}
+	return path
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"container/list"
+	"fmt"
 	"log"
 	"strconv"
 	"sync"
@@ -32,6 +33,7 @@ type Team struct {
 	Status     TeamStatus `json:"status"`
 	WaitTime   int        `json:"waitTime"`
 	Mode       string     `json:"mode"`
+	Profile    string     `json:"profile"`
 	Calling    int        `json:"calling"`
 }
 
@@ -53,13 +55,13 @@ func NewQueue(srv *Srv) *Queue {
 	return &q
 }
 
-func (q *Queue) AddTeamToQueue(teamSize int, mode string) int {
+func (q *Queue) AddTeamToQueue(teamSize int, mode string, profile string) int {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 	defer q.updateHallData()
 	q.cur += 1
 	id := strconv.Itoa(q.cur)
-	t := Team{Size: teamSize, ID: id, Status: TS_Waiting, Mode: mode, Calling: 0}
+	t := Team{Size: teamSize, ID: id, Status: TS_Waiting, Mode: mode, Profile: profile, Calling: 0}
 	element := q.li.PushBack(&t)
 	q.dict[id] = element
 	return q.cur
@@ -131,6 +133,22 @@ func (q *Queue) TeamFinishMatch(teamID string) {
 	delete(q.dict, teamID)
 }
 
+// TeamFinishPractice puts a team back to waiting at the top of the hall
+// after a practice match, the game it queued for is still to be played
+func (q *Queue) TeamFinishPractice(teamID string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	defer q.updateHallData()
+	element := q.dict[teamID]
+	if element == nil {
+		return
+	}
+	team := element.Value.(*Team)
+	team.Status = TS_Waiting
+	team.Calling = 0
+	q.li.MoveToFront(element)
+}
+
 func (q *Queue) TeamCall(teamID string) {
 	q.lock.RLock()
 	defer q.lock.RUnlock()
@@ -194,6 +212,28 @@ func (q *Queue) TeamChangeMode(teamID string, mode string) {
 	team.Mode = mode
 }
 
+func (q *Queue) TeamChangeProfile(teamID string, profile string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	defer q.updateHallData()
+	element := q.dict[teamID]
+	if element == nil {
+		return
+	}
+	team := element.Value.(*Team)
+	team.Profile = profile
+}
+
+func (q *Queue) TeamProfile(teamID string) string {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	element := q.dict[teamID]
+	if element == nil {
+		return ""
+	}
+	return element.Value.(*Team).Profile
+}
+
 func (q *Queue) TeamDelay(teamID string) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
@@ -216,16 +256,21 @@ func (q *Queue) TeamDelay(teamID string) {
 	}
 }
 
-func (q *Queue) TeamAddPlayer(teamID string) {
+// TeamAddPlayer fails when the team already has maxTeamSize players
+func (q *Queue) TeamAddPlayer(teamID string) error {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 	defer q.updateHallData()
 	element := q.dict[teamID]
 	if element == nil {
-		return
+		return nil
 	}
 	team := element.Value.(*Team)
+	if max := q.srv.GetOptions().MaxTeamSize; team.Size >= max {
+		return fmt.Errorf("队伍最多%v人", max)
+	}
 	team.Size += 1
+	return nil
 }
 
 func (q *Queue) TeamRemovePlayer(teamID string) {
@@ -31,7 +31,7 @@ type QuickChecker struct {
 func NewQuickChecker(srv *Srv) *QuickChecker {
 	qc := QuickChecker{}
 	qc.srv = srv
-	qc.receivers = GetLaserPair().GetValidReceivers(true)
+	qc.receivers = qc.srv.laserPair.GetValidReceivers(true)
 	qc.statusMap = make(map[string]ReceiverStatus)
 	qc.enterCh = make(chan string)
 	qc.leaveCh = make(chan string)
@@ -97,11 +97,11 @@ func (qc *QuickChecker) record() {
 			ret = append(ret, k)
 		}
 	}
-	GetLaserPair().RecordBrokens(ret)
+	qc.srv.laserPair.RecordBrokens(ret)
 }
 
 func (qc *QuickChecker) toggleAllLasers(status string) {
-	senders := GetLaserPair().GetValidSenders()
+	senders := qc.srv.laserPair.GetValidSenders()
 	for id, li := range senders {
 		info := arduinoInfoFromID(id)
 		msg := NewInboxMessage()
@@ -171,13 +171,13 @@ func (qc *QuickChecker) blinkLoop() {
 }
 
 func (qc *QuickChecker) blink(receiver string) {
-	if info, sender := GetLaserPair().FindByReceiver(receiver); info != nil {
+	if info, sender := qc.srv.laserPair.FindByReceiver(receiver); info != nil {
 		qc.enterCh <- sender
 	}
 }
 
 func (qc *QuickChecker) stopBlink(receiver string) {
-	if info, sender := GetLaserPair().FindByReceiver(receiver); info != nil {
+	if info, sender := qc.srv.laserPair.FindByReceiver(receiver); info != nil {
 		qc.leaveCh <- sender
 	}
 }
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var _ = log.Printf
+
+const (
+	recordDirIn  = "in"
+	recordDirOut = "out"
+	recordDirEnd = "end"
+)
+
+// RecordHeader is the first line of a match recording, it holds what is
+// needed to build the same match again
+type RecordHeader struct {
+	MatchID     uint      `json:"matchID"`
+	Mode        string    `json:"mode"`
+	Profile     string    `json:"profile"`
+	TeamID      string    `json:"teamID"`
+	Seed        int64     `json:"seed"`
+	Members     []string  `json:"members"`
+	IsSimulator bool      `json:"isSimulator"`
+	Start       time.Time `json:"start"`
+}
+
+// RecordEntry is one line after the header. Dir "in" is an input the match
+// handled before running tick Tick, "out" a command sent to the hardware and
+// "end" the final state of the match in Data.
+type RecordEntry struct {
+	Tick          int                    `json:"tick"`
+	Time          int64                  `json:"t"` // ms since the match started
+	Dir           string                 `json:"dir"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+	Address       *InboxAddress          `json:"address,omitempty"`
+	AddAddress    *InboxAddress          `json:"addAddress,omitempty"`
+	RemoveAddress *InboxAddress          `json:"removeAddress,omitempty"`
+	Addrs         []InboxAddress         `json:"addrs,omitempty"`
+}
+
+func (e *RecordEntry) inboxMessage() *InboxMessage {
+	msg := NewInboxMessage()
+	if e.Data != nil {
+		msg.Data = e.Data
+	}
+	msg.Address = e.Address
+	msg.AddAddress = e.AddAddress
+	msg.RemoveAddress = e.RemoveAddress
+	return msg
+}
+
+func RecordPath(dir string, matchID uint) string {
+	return filepath.Join(dir, fmt.Sprintf("match-%d.jsonl", matchID))
+}
+
+// matchRecorder writes the recording of one match. Inputs are written from
+// the match goroutine, outbound commands from whoever calls Srv.send.
+type matchRecorder struct {
+	f     *os.File
+	enc   *json.Encoder
+	lock  *sync.Mutex
+	clock Clock
+	start time.Time
+	tick  int
+}
+
+func newMatchRecorder(dir string, header *RecordHeader, clock Clock) (*matchRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(RecordPath(dir, header.MatchID))
+	if err != nil {
+		return nil, err
+	}
+	r := matchRecorder{}
+	r.f = f
+	r.enc = json.NewEncoder(f)
+	r.lock = new(sync.Mutex)
+	r.clock = clock
+	r.start = header.Start
+	if err := r.enc.Encode(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (r *matchRecorder) setTick(tick int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.tick = tick
+}
+
+func (r *matchRecorder) write(e *RecordEntry) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f == nil {
+		return
+	}
+	e.Tick = r.tick
+	e.Time = int64(r.clock.Now().Sub(r.start) / time.Millisecond)
+	if err := r.enc.Encode(e); err != nil {
+		log.Printf("write match record error:%v\n", err)
+	}
+}
+
+func (r *matchRecorder) input(msg *InboxMessage) {
+	r.write(&RecordEntry{
+		Dir:           recordDirIn,
+		Data:          msg.Data,
+		Address:       msg.Address,
+		AddAddress:    msg.AddAddress,
+		RemoveAddress: msg.RemoveAddress,
+	})
+}
+
+func (r *matchRecorder) output(msg *InboxMessage, addrs []InboxAddress) {
+	r.write(&RecordEntry{Dir: recordDirOut, Data: msg.Data, Addrs: addrs})
+}
+
+func (r *matchRecorder) close(final map[string]interface{}) {
+	r.write(&RecordEntry{Dir: recordDirEnd, Data: final})
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+}
+
+func isHardwareAddress(addr InboxAddress) bool {
+	return addr.Type.IsArduinoControllerType() || addr.Type == InboxAddressTypeWearableDevice
+}
+
+// recordOutbound hands the hardware part of a sent message to every match
+// being recorded, the arena devices are shared by all of them
+func (s *Srv) recordOutbound(msg *InboxMessage, addrs []InboxAddress) {
+	s.recordLock.Lock()
+	defer s.recordLock.Unlock()
+	if len(s.recorders) == 0 {
+		return
+	}
+	hw := make([]InboxAddress, 0)
+	for _, addr := range addrs {
+		if isHardwareAddress(addr) {
+			hw = append(hw, addr)
+		}
+	}
+	if len(hw) == 0 {
+		return
+	}
+	for _, r := range s.recorders {
+		r.output(msg, hw)
+	}
+}
+
+func (s *Srv) addRecorder(id uint, r *matchRecorder) {
+	s.recordLock.Lock()
+	defer s.recordLock.Unlock()
+	s.recorders[id] = r
+}
+
+func (s *Srv) removeRecorder(id uint) {
+	s.recordLock.Lock()
+	defer s.recordLock.Unlock()
+	delete(s.recorders, id)
+}
+
+func (m *Match) startRecording() {
+	dir := m.srv.recordDir
+	if dir == "" {
+		return
+	}
+	header := RecordHeader{}
+	header.MatchID = m.ID
+	header.Mode = m.Mode
+	header.Profile = m.Profile
+	header.TeamID = m.TeamID
+	header.Seed = m.matchData.Seed
+	header.Members = make([]string, len(m.Member))
+	for i, player := range m.Member {
+		header.Members[i] = player.ControllerID
+	}
+	header.IsSimulator = m.isSimulator
+	header.Start = m.clock.Now()
+	r, err := newMatchRecorder(dir, &header, m.clock)
+	if err != nil {
+		log.Printf("start match record error:%v\n", err)
+		return
+	}
+	m.recorder = r
+	m.srv.addRecorder(m.ID, r)
+}
+
+func (m *Match) stopRecording() {
+	if m.recorder == nil {
+		return
+	}
+	m.srv.removeRecorder(m.ID)
+	m.recorder.close(map[string]interface{}{
+		"stage":  m.Stage,
+		"gold":   m.Gold,
+		"energy": m.Energy,
+	})
+	m.recorder = nil
+}
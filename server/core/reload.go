@@ -0,0 +1,90 @@
+package core
+
+import (
+	"github.com/labstack/echo"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var _ = log.Printf
+
+// fields derived from other options, a change of them is already reported
+// by the option they are built from
+var derivedOptions = map[string]bool{
+	"WallRects":       true,
+	"Buttons":         true,
+	"MainArduinoInfo": true,
+	"TileAdjacency":   true,
+}
+
+// ReloadConfig re-reads and validates cfg.toml, warmup.toml, survey.toml and
+// achievements.toml
+// and swaps them in for the next match. Running matches keep the options they
+// started with. Nothing is swapped if any file is invalid.
+func (s *Srv) ReloadConfig() ([]string, error) {
+	newOpt, newSurvey, newAchievements, err := loadReloadable(s.paths)
+	if err != nil {
+		return nil, err
+	}
+	s.configLock.Lock()
+	oldOpt, oldSurvey, oldAchievements := s.opt, s.survey, s.achievements
+	s.opt, s.survey, s.achievements = newOpt, newSurvey, newAchievements
+	s.configLock.Unlock()
+	changed := diffOptions(oldOpt, newOpt)
+	if !reflect.DeepEqual(oldSurvey, newSurvey) {
+		changed = append(changed, "survey")
+	}
+	if !reflect.DeepEqual(oldAchievements, newAchievements) {
+		changed = append(changed, "achievements")
+	}
+	log.Printf("config reloaded, changed:%v\n", changed)
+	if scoreInfoChanged(oldOpt, newOpt) {
+		s.broadcastScoreInfo()
+	}
+	s.sendMsgs("configReloaded", map[string]interface{}{"changed": changed}, InboxAddressTypeAdminDevice)
+	return changed, nil
+}
+
+// ReloadConfigHandler is the http interface of ReloadConfig
+func (s *Srv) ReloadConfigHandler(c echo.Context) error {
+	changed, err := s.ReloadConfig()
+	d := make(map[string]interface{})
+	if err != nil {
+		d["code"] = 1
+		d["error"] = err.Error()
+	} else {
+		d["code"] = 0
+		d["changed"] = changed
+	}
+	return c.JSON(http.StatusOK, d)
+}
+
+func diffOptions(a *MatchOptions, b *MatchOptions) []string {
+	changed := make([]string, 0)
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		if derivedOptions[name] {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, strings.ToLower(name[:1])+name[1:])
+		}
+	}
+	return changed
+}
+
+func scoreInfoChanged(a *MatchOptions, b *MatchOptions) bool {
+	return a.T1 != b.T1 || a.T2 != b.T2 || a.T3 != b.T3 || a.TRampage != b.TRampage ||
+		a.UploadTime != b.UploadTime || a.HeartbeatTime != b.HeartbeatTime ||
+		a.SubUploadTime != b.SubUploadTime || a.SubHeartbeatTime != b.SubHeartbeatTime
+}
+
+// push new button timing to every connected main arduino, they confirm with
+// confirm_init_score as on connect
+func (s *Srv) broadcastScoreInfo() {
+	s.sends(s.scoreInfoMessage(), InboxAddressTypeMainArduinoDevice)
+}
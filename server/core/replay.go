@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReplayMatch feeds the recording at path into a headless match built from
+// c and writes the timeline of inputs, stage, gold, energy and laser
+// positions to w. The match uses the options of c, a recording replayed with
+// other options than it was played with can take another course.
+func ReplayMatch(c *SrvConfig, path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	header := RecordHeader{}
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("read record header error:%v", err)
+	}
+	entries := make([]*RecordEntry, 0)
+	var end *RecordEntry
+	for {
+		e := RecordEntry{}
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("read record error:%v", err)
+		}
+		switch e.Dir {
+		case recordDirIn:
+			entries = append(entries, &e)
+		case recordDirEnd:
+			end = &e
+		}
+	}
+
+	cfg := *c
+	clock := NewManualClock(header.Start)
+	cfg.Clock = clock
+	cfg.RecordDir = ""
+	cfg.IsSimulator = header.IsSimulator
+	s := NewSrv(&cfg)
+	go func() {
+		for range s.mChan {
+		}
+	}()
+	if GetGameMode(header.Mode) == nil {
+		return fmt.Errorf("unknown mode:%v", header.Mode)
+	}
+	opt, err := s.GetOptions().WithProfile(header.Profile)
+	if err != nil {
+		return err
+	}
+	md := MatchData{}
+	md.ID = header.MatchID
+	md.Seed = header.Seed
+	m := NewMatch(s, opt, header.Members, &md, header.Mode, header.TeamID, header.IsSimulator)
+
+	fmt.Fprintf(w, "match %v mode:%v profile:%v seed:%v members:%v\n", header.MatchID, header.Mode, header.Profile, header.Seed, strings.Join(header.Members, ","))
+	t := replayTimeline{w: w, m: m, clock: clock, start: header.Start}
+	m.Start()
+	t.print()
+	next := 0
+	for tick := 0; end == nil || tick <= end.Tick; tick++ {
+		now := clock.Now().Sub(header.Start)
+		for ; next < len(entries) && entries[next].Tick == tick; next++ {
+			e := entries[next]
+			// inputs keep the time they arrived at, the clock never goes back
+			if at := time.Duration(e.Time) * time.Millisecond; at > now {
+				clock.Advance(at - now)
+				now = at
+			}
+			t.input(e)
+			m.Feed(e.inboxMessage())
+		}
+		if !m.Step() {
+			break
+		}
+		t.print()
+		clock.Advance(matchTickInterval)
+	}
+	t.print()
+	if end != nil {
+		fmt.Fprintf(w, "recorded end stage:%v gold:%v energy:%v\n", end.Data["stage"], end.Data["gold"], end.Data["energy"])
+	}
+	fmt.Fprintf(w, "replayed end stage:%v gold:%v energy:%.1f\n", m.Stage, m.Gold, m.Energy)
+	return nil
+}
+
+type replayTimeline struct {
+	w      io.Writer
+	m      *Match
+	clock  *ManualClock
+	start  time.Time
+	stage  string
+	gold   string
+	lasers string
+}
+
+func (t *replayTimeline) prefix() string {
+	return fmt.Sprintf("[%8.3fs #%d]", t.clock.Now().Sub(t.start).Seconds(), t.m.ticks)
+}
+
+func (t *replayTimeline) input(e *RecordEntry) {
+	fields := make([]string, 0)
+	for k, v := range e.Data {
+		if k != "cmd" {
+			fields = append(fields, fmt.Sprintf("%v=%v", k, v))
+		}
+	}
+	from := ""
+	switch {
+	case e.Address != nil:
+		from = e.Address.String()
+	case e.AddAddress != nil:
+		from = "online " + e.AddAddress.String()
+	case e.RemoveAddress != nil:
+		from = "offline " + e.RemoveAddress.String()
+	}
+	sort.Strings(fields)
+	fmt.Fprintf(t.w, "%v > %v %v %v\n", t.prefix(), e.Data["cmd"], from, strings.Join(fields, " "))
+}
+
+// print writes the parts of the state that changed since the last call
+func (t *replayTimeline) print() {
+	m := t.m
+	if m.Stage != t.stage {
+		t.stage = m.Stage
+		fmt.Fprintf(t.w, "%v stage %v\n", t.prefix(), m.Stage)
+	}
+	gold := fmt.Sprintf("gold %v energy %.1f", m.Gold, m.Energy)
+	for _, side := range m.Sides {
+		gold += fmt.Sprintf(" side%v %v/%.1f", side.Index, side.Gold, side.Energy)
+	}
+	if gold != t.gold {
+		t.gold = gold
+		fmt.Fprintf(t.w, "%v %v\n", t.prefix(), gold)
+	}
+	lasers := make([]string, len(m.Lasers))
+	for i, l := range m.Lasers {
+		lasers[i] = m.laserTiles(l)
+	}
+	if s := strings.Join(lasers, " "); s != t.lasers {
+		t.lasers = s
+		fmt.Fprintf(t.w, "%v lasers %v\n", t.prefix(), s)
+	}
+}
+
+// laserTiles shows the tile a laser is on, a moving laser also shows the
+// tile it moves to
+func (m *Match) laserTiles(l LaserInterface) string {
+	tile := func(i int) string {
+		p := m.opt.IntToTile(i)
+		return fmt.Sprintf("(%d,%d)", p.X, p.Y)
+	}
+	switch l := l.(type) {
+	case *Laser:
+		if l.p2 >= 0 {
+			return tile(l.p) + "->" + tile(l.p2)
+		}
+		return tile(l.p)
+	case *SimuLaser:
+		return tile(l.p)
+	}
+	return "?"
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+var _ = log.Printf
+
+// ComboRule applies to the hits that make a streak of Streak or more buttons,
+// the rule with the largest Streak that is not above the streak wins. Window
+// is the most seconds since the previous hit for the streak to go on, one
+// entry per team size.
+type ComboRule struct {
+	Streak     int       `toml:"streak"`
+	Window     []float64 `toml:"window"`
+	Extra      float64   `toml:"extra"`      // energy on top of the button's
+	Multiplier float64   `toml:"multiplier"` // of the button's gold, 0 is 1
+}
+
+// ScoringOptions is the [scoring] section of cfg.toml. Every rule left out
+// is built from the older options (goldBonus, energyBonus, firstComboInterval,
+// firstComboExtra, comboExtra, mode1TouchPunish, mode2TouchPunish) and
+// scores like before.
+type ScoringOptions struct {
+	LevelGold   [][]int     `toml:"levelGold"`   // per mode column, gold of a button of level 1-3
+	LevelEnergy [][]float64 `toml:"levelEnergy"` // per button level 0-3, energy per team size
+	Combos      []ComboRule `toml:"combos"`
+	// EnergyDecay is the energy lost every second out of rampage
+	EnergyDecay float64 `toml:"energyDecay"`
+	// RampageEnergy is the part of maxEnergy that starts a rampage, 0 is 1
+	RampageEnergy float64 `toml:"rampageEnergy"`
+	// RampageApart starts a rampage without the players standing together
+	RampageApart bool `toml:"rampageApart"`
+	// UseComboInterval makes comboInterval the window of the second and later
+	// hits of the default combos, which have always used firstComboInterval
+	UseComboInterval bool `toml:"useComboInterval"`
+}
+
+// ScoringRules are the resolved rules a match scores with
+type ScoringRules struct {
+	levelGold     [][]int
+	levelEnergy   [][]float64
+	combos        []ComboRule
+	touchPunish   [][]int
+	energyDecay   float64
+	rampageEnergy float64
+	rampageApart  bool
+}
+
+func newScoringRules(opt *MatchOptions) *ScoringRules {
+	so := &opt.Scoring
+	r := ScoringRules{}
+	r.levelGold = so.LevelGold
+	if len(r.levelGold) == 0 {
+		r.levelGold = make([][]int, len(opt.GoldBonus))
+		for i, gold := range opt.GoldBonus {
+			r.levelGold[i] = []int{gold, gold, gold}
+		}
+	}
+	r.levelEnergy = so.LevelEnergy
+	if len(r.levelEnergy) == 0 {
+		r.levelEnergy = opt.EnergyBonus
+	}
+	r.combos = so.Combos
+	if len(r.combos) == 0 {
+		// the second window has always been firstComboInterval as well
+		window := opt.FirstComboInterval
+		if so.UseComboInterval {
+			window = opt.ComboInterval
+		}
+		r.combos = []ComboRule{
+			{Streak: 1, Window: opt.FirstComboInterval, Extra: opt.FirstComboExtra, Multiplier: 1},
+			{Streak: 2, Window: window, Extra: opt.ComboExtra, Multiplier: 1},
+		}
+	}
+	r.touchPunish = [][]int{opt.Mode1TouchPunish, opt.Mode2TouchPunish}
+	r.energyDecay = so.EnergyDecay
+	r.rampageEnergy = so.RampageEnergy
+	if r.rampageEnergy <= 0 {
+		r.rampageEnergy = 1
+	}
+	r.rampageApart = so.RampageApart
+	return &r
+}
+
+// comboRule is the rule for a streak of n hits, nil when no rule covers it
+func (r *ScoringRules) comboRule(n int) *ComboRule {
+	var rule *ComboRule
+	for i := range r.combos {
+		if c := &r.combos[i]; c.Streak <= n && (rule == nil || c.Streak > rule.Streak) {
+			rule = c
+		}
+	}
+	return rule
+}
+
+// hit continues or breaks the streak of p with a hit sec seconds after the
+// previous one, the returned rule applies to this hit
+func (r *ScoringRules) hit(p *Player, sec float64, teamSize int) *ComboRule {
+	next := r.comboRule(p.Combo + 1)
+	if next != nil && sec <= teamSizeFloat(next.Window, teamSize) {
+		p.Combo += 1
+		if p.Combo > p.MaxCombo {
+			p.MaxCombo = p.Combo
+		}
+		if p.Combo == 1 {
+			p.ComboCount += 1
+		}
+		return next
+	}
+	p.Combo = 0
+	return nil
+}
+
+func (r *ScoringRules) buttonGold(modeIndex int, level int, combo *ComboRule) int {
+	row := r.levelGold[modeIndex]
+	gold := row[len(row)-1]
+	if level >= 1 && level <= len(row) {
+		gold = row[level-1]
+	}
+	if combo != nil && combo.Multiplier > 0 {
+		return int(math.Floor(float64(gold)*combo.Multiplier + 0.5))
+	}
+	return gold
+}
+
+func (r *ScoringRules) buttonEnergy(level int, teamSize int, combo *ComboRule) float64 {
+	energy := teamSizeFloat(r.levelEnergy[level], teamSize)
+	if combo != nil {
+		energy += combo.Extra
+	}
+	return energy
+}
+
+func (r *ScoringRules) touchGold(modeIndex int, teamSize int) int {
+	return teamSizeInt(r.touchPunish[modeIndex], teamSize)
+}
+
+// decay is energy after sec seconds out of rampage
+func (r *ScoringRules) decay(energy float64, sec float64) float64 {
+	if r.energyDecay <= 0 {
+		return energy
+	}
+	return math.Max(energy-r.energyDecay*sec, 0)
+}
+
+// canRampage reports whether a team or side with energy can start a
+// rampage, together is whether its players stand on the same tile
+func (r *ScoringRules) canRampage(energy float64, maxEnergy float64, together bool) bool {
+	return energy >= maxEnergy*r.rampageEnergy && (together || r.rampageApart)
+}
+
+// decayEnergy takes the decay of sec seconds from the team or every side
+// that is not in rampage
+func (m *Match) decayEnergy(sec float64) {
+	if m.RampageTime <= 0 {
+		m.Energy = m.scoring.decay(m.Energy, sec)
+	}
+	for _, side := range m.Sides {
+		if side.RampageTime <= 0 {
+			side.Energy = m.scoring.decay(side.Energy, sec)
+		}
+	}
+}
+
+func (o *ScoringOptions) Validate(base *MatchOptions) error {
+	var errs ValidationErrors
+	if len(o.LevelGold) > 0 && len(o.LevelGold) != len(base.GoldBonus) {
+		errs.add("levelGold", "must have %v rows like goldBonus, got %v", len(base.GoldBonus), len(o.LevelGold))
+	}
+	for i, row := range o.LevelGold {
+		if len(row) != buttonLevelNum-1 {
+			errs.add(fmt.Sprintf("levelGold[%d]", i), "must have %v entries, one per button level 1-3, got %v", buttonLevelNum-1, len(row))
+		}
+	}
+	if len(o.LevelEnergy) > 0 && len(o.LevelEnergy) != buttonLevelNum {
+		errs.add("levelEnergy", "must have %v rows, one per button level, got %v", buttonLevelNum, len(o.LevelEnergy))
+	}
+	for i, row := range o.LevelEnergy {
+		errs.teamSizeTable(fmt.Sprintf("levelEnergy[%d]", i), len(row), base.MaxTeamSize)
+	}
+	streaks := make(map[int]bool)
+	for i, c := range o.Combos {
+		field := fmt.Sprintf("combos[%d]", i)
+		if c.Streak < 1 {
+			errs.add(field+".streak", "must be at least 1, got %v", c.Streak)
+		} else if streaks[c.Streak] {
+			errs.add(field+".streak", "%v is used twice", c.Streak)
+		}
+		streaks[c.Streak] = true
+		errs.teamSizeTable(field+".window", len(c.Window), base.MaxTeamSize)
+		if c.Multiplier < 0 {
+			errs.add(field+".multiplier", "must not be negative, got %v", c.Multiplier)
+		}
+	}
+	if len(o.Combos) > 0 && !streaks[1] {
+		errs.add("combos", "must have a rule for streak 1")
+	}
+	if len(o.Combos) > 0 && o.UseComboInterval {
+		errs.add("useComboInterval", "only applies to the default combos, set the windows in combos instead")
+	}
+	if o.EnergyDecay < 0 {
+		errs.add("energyDecay", "must not be negative, got %v", o.EnergyDecay)
+	}
+	if o.RampageEnergy < 0 || o.RampageEnergy > 1 {
+		errs.add("rampageEnergy", "must be between 0 and 1, got %v", o.RampageEnergy)
+	}
+	return errs.err()
+}
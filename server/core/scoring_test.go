@@ -0,0 +1,178 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// the shipped cfg.toml has no [scoring] rules, so these are the numbers the
+// game scored with before the rules engine
+
+func shippedOptions(t *testing.T) *MatchOptions {
+	c, err := LoadSrvConfig(testConfigPaths, true)
+	if err != nil {
+		t.Fatalf("load config error:%v", err)
+	}
+	return c.Options
+}
+
+func TestScoringHit(t *testing.T) {
+	type hit struct {
+		sec        float64
+		combo      int
+		comboCount int
+		streak     int // of the returned rule, 0 for nil
+	}
+	tests := []struct {
+		name     string
+		teamSize int
+		useCI    bool
+		hits     []hit
+	}{
+		{"first hit of the match", 2, false, []hit{
+			{math.Inf(1), 0, 0, 0},
+		}},
+		{"streak goes on within firstComboInterval", 2, false, []hit{
+			{3.9, 1, 1, 1},
+			{4.0, 2, 1, 2},
+			{4.0, 3, 1, 2},
+		}},
+		{"streak breaks after firstComboInterval", 2, false, []hit{
+			{1.0, 1, 1, 1},
+			{4.1, 0, 1, 0},
+			{1.0, 1, 2, 1},
+		}},
+		{"one player has the longest window", 1, false, []hit{
+			{5.0, 1, 1, 1},
+			{5.0, 2, 1, 2},
+			{5.1, 0, 1, 0},
+		}},
+		{"four players have the shortest window", 4, false, []hit{
+			{2.0, 1, 1, 1},
+			{2.5, 0, 1, 0},
+		}},
+		{"larger teams use the last window", 6, false, []hit{
+			{2.0, 1, 1, 1},
+			{2.1, 0, 1, 0},
+		}},
+		{"useComboInterval from the second hit", 2, true, []hit{
+			{4.0, 1, 1, 1},
+			{3.0, 2, 1, 2},
+			{3.5, 0, 1, 0},
+		}},
+	}
+	for _, tt := range tests {
+		opt := shippedOptions(t)
+		opt.Scoring.UseComboInterval = tt.useCI
+		r := newScoringRules(opt)
+		p := NewPlayer("1", true)
+		for i, h := range tt.hits {
+			rule := r.hit(p, h.sec, tt.teamSize)
+			streak := 0
+			if rule != nil {
+				streak = rule.Streak
+			}
+			if p.Combo != h.combo || p.ComboCount != h.comboCount || streak != h.streak {
+				t.Errorf("%v: hit %d after %vs: combo %v comboCount %v rule %v, want %v %v %v",
+					tt.name, i, h.sec, p.Combo, p.ComboCount, streak, h.combo, h.comboCount, h.streak)
+			}
+		}
+	}
+}
+
+func TestScoringButtonEnergy(t *testing.T) {
+	r := newScoringRules(shippedOptions(t))
+	tests := []struct {
+		level    int
+		teamSize int
+		streak   int // of the combo rule, 0 without a combo
+		want     float64
+	}{
+		{1, 1, 0, 50},
+		{1, 2, 0, 37},
+		{2, 3, 0, 22},
+		{3, 4, 0, 12},
+		{3, 6, 0, 12},
+		{1, 1, 1, 65}, // firstComboExtra
+		{2, 2, 1, 45},
+		{1, 3, 2, 46}, // comboExtra
+		{3, 4, 7, 32},
+	}
+	for _, tt := range tests {
+		var combo *ComboRule
+		if tt.streak > 0 {
+			combo = r.comboRule(tt.streak)
+		}
+		if got := r.buttonEnergy(tt.level, tt.teamSize, combo); got != tt.want {
+			t.Errorf("buttonEnergy(level %v, %v players, streak %v) = %v, want %v", tt.level, tt.teamSize, tt.streak, got, tt.want)
+		}
+	}
+}
+
+func TestScoringButtonGold(t *testing.T) {
+	r := newScoringRules(shippedOptions(t))
+	tests := []struct {
+		modeIndex int
+		level     int
+		streak    int
+		want      int
+	}{
+		{0, 1, 0, 11},
+		{0, 2, 0, 11},
+		{0, 3, 2, 11},
+		{1, 1, 0, 5},
+		{1, 2, 1, 5},
+		{1, 3, 0, 5},
+	}
+	for _, tt := range tests {
+		var combo *ComboRule
+		if tt.streak > 0 {
+			combo = r.comboRule(tt.streak)
+		}
+		if got := r.buttonGold(tt.modeIndex, tt.level, combo); got != tt.want {
+			t.Errorf("buttonGold(mode %v, level %v, streak %v) = %v, want %v", tt.modeIndex, tt.level, tt.streak, got, tt.want)
+		}
+	}
+}
+
+func TestScoringTouchGold(t *testing.T) {
+	r := newScoringRules(shippedOptions(t))
+	want := map[int][]int{
+		0: {100, 50, 30, 20, 20}, // mode1TouchPunish, 1-5 players
+		1: {30, 20, 20, 15, 15},  // mode2TouchPunish
+	}
+	for modeIndex, gold := range want {
+		for i, w := range gold {
+			if got := r.touchGold(modeIndex, i+1); got != w {
+				t.Errorf("touchGold(mode %v, %v players) = %v, want %v", modeIndex, i+1, got, w)
+			}
+		}
+	}
+}
+
+func TestScoringCanRampage(t *testing.T) {
+	opt := shippedOptions(t)
+	max := opt.MaxEnergy
+	if max != 800 {
+		t.Fatalf("maxEnergy is %v, want 800", max)
+	}
+	tests := []struct {
+		energy   float64
+		together bool
+		apart    bool // rampageApart
+		want     bool
+	}{
+		{800, true, false, true},
+		{800, false, false, false},
+		{799.9, true, false, false},
+		{800, false, true, true},
+		{799.9, false, true, false},
+	}
+	for _, tt := range tests {
+		opt.Scoring.RampageApart = tt.apart
+		r := newScoringRules(opt)
+		if got := r.canRampage(tt.energy, max, tt.together); got != tt.want {
+			t.Errorf("canRampage(%v, together %v, rampageApart %v) = %v, want %v", tt.energy, tt.together, tt.apart, got, tt.want)
+		}
+	}
+}
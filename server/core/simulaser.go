@@ -13,8 +13,7 @@ type SimuLaser struct {
 	IsClosed bool `json:"isClosed"`
 	//private
 	player    *Player
-	dest      int
-	pathMap   map[int]int
+	chase     ChaseStrategy
 	p         int
 	match     *Match
 	pauseTime float64
@@ -24,13 +23,12 @@ func NewSimuLaser(p P, player *Player, match *Match) *SimuLaser {
 	l := SimuLaser{}
 	l.IsPause = true
 	l.player = player
-	l.dest = -1
 	l.match = match
-	l.pathMap = make(map[int]int)
+	l.chase = match.newChaseStrategy()
 	l.p = l.getOpt().TilePosToInt(p)
 	l.Pos = l.getOpt().RealPosition(p)
 	l.IsClosed = false
-	l.Pause(GetOptions().LaserAppearTime)
+	l.Pause(l.getOpt().LaserAppearTime)
 	return &l
 }
 
@@ -40,10 +38,21 @@ func (l *SimuLaser) Pause(t float64) int {
 	return l.p
 }
 
+func (l *SimuLaser) Tile() int {
+	return l.p
+}
+
 func (l *SimuLaser) IsFollow(cid string) bool {
 	return l.player.ControllerID == cid
 }
 
+// a simulated laser has nothing to turn off, it stops with the ticks
+func (l *SimuLaser) Suspend() {
+}
+
+func (l *SimuLaser) Resume() {
+}
+
 func (l *SimuLaser) Close() {
 	l.IsClosed = true
 }
@@ -144,37 +153,8 @@ func (l *SimuLaser) Tick(dt float64) {
 }
 
 func (l *SimuLaser) findPath() int {
-	l.fillPath()
-	next, min := l.p, l.pathMap[l.p]
-	for _, i := range l.getOpt().TileAdjacency[l.p] {
-		if l.pathMap[i] < min {
-			min = l.pathMap[i]
-			next = i
-		}
-	}
-	return next
-}
-
-func (l *SimuLaser) fillPath() {
 	p, _ := l.getOpt().TilePosition(l.player.Pos)
-	dest := l.getOpt().TilePosToInt(p)
-	if l.dest == dest {
-		return
-	}
-	l.dest = dest
-	for i := 0; i < l.getOpt().ArenaWidth*l.getOpt().ArenaHeight; i++ {
-		l.pathMap[i] = 10000
-	}
-	var fill func(x int, v int)
-	fill = func(x int, v int) {
-		l.pathMap[x] = v
-		for _, i := range l.getOpt().TileAdjacency[x] {
-			if l.pathMap[i] > v+1 {
-				fill(i, v+1)
-			}
-		}
-	}
-	fill(l.dest, 0)
+	return l.chase.Next(&ChaseContext{l.match, l, l.p, l.getOpt().TilePosToInt(p)})
 }
 
 func (l *SimuLaser) getOpt() *MatchOptions {
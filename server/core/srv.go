@@ -10,6 +10,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type AdminMode int
@@ -22,8 +23,10 @@ const (
 var _ = log.Println
 
 type pendingMatch struct {
-	ids  []string
-	mode string
+	ids     []string
+	mode    string
+	profile string
+	teamID  string
 }
 
 type Srv struct {
@@ -35,22 +38,56 @@ type Srv struct {
 	pDict            map[string]*PlayerController
 	aDict            map[string]*ArduinoController
 	mDict            map[uint]*Match
+	arena            *Arena
+	states           *stateFeed
 	adminMode        AdminMode
 	isSimulator      bool
 	qc               *QuickChecker
+	opt              *MatchOptions
+	survey           *Survey
+	achievements     *Achievements
+	laserPair        *LaserPair
+	paths            ConfigPaths
+	configLock       *sync.RWMutex
+	clock            Clock
+	recordDir        string
+	recorders        map[uint]*matchRecorder
+	recordLock       *sync.Mutex
 }
 
-func NewSrv(isSimulator bool) *Srv {
+// NewSrv runs a single arena with its own connections and database, a Hub
+// runs several arenas together
+func NewSrv(c *SrvConfig) *Srv {
+	return newSrv(c, NewInbox(), NewDb())
+}
+
+func newSrv(c *SrvConfig, inbox *Inbox, db *DB) *Srv {
 	s := Srv{}
-	s.isSimulator = isSimulator
-	s.inbox = NewInbox(&s)
+	s.isSimulator = c.IsSimulator
+	s.opt = c.Options
+	s.survey = c.Survey
+	s.achievements = c.Achievements
+	s.laserPair = c.LaserPair
+	s.paths = c.Paths
+	s.configLock = new(sync.RWMutex)
+	s.clock = c.Clock
+	if s.clock == nil {
+		s.clock = realClock{}
+	}
+	s.recordDir = c.RecordDir
+	s.recorders = make(map[uint]*matchRecorder)
+	s.recordLock = new(sync.Mutex)
+	s.inbox = inbox
 	s.queue = NewQueue(&s)
-	s.db = NewDb()
+	s.db = db
 	s.inboxMessageChan = make(chan *InboxMessage, 1)
 	s.mChan = make(chan MatchEvent)
 	s.pDict = make(map[string]*PlayerController)
 	s.aDict = make(map[string]*ArduinoController)
 	s.mDict = make(map[uint]*Match)
+	s.arena = NewArena(c.Arena)
+	s.states = newStateFeed()
+	s.inbox.addArena(c.Arena, s.onInboxMessageArrived)
 	s.adminMode = AdminModeNormal
 	s.initArduinoControllers()
 	return &s
@@ -67,29 +104,79 @@ func (s *Srv) Run(tcpAddr string, udpAddr string, dbPath string) {
 	s.mainLoop()
 }
 
+// Name is the name of the arena the Srv runs
+func (s *Srv) Name() string {
+	return s.arena.Name
+}
+
 func (s *Srv) ListenWebSocket(conn *websocket.Conn) {
 	log.Println("got new ws connection")
 	s.inbox.ListenConnection(NewInboxWsConnection(conn))
 }
 
+// GetOptions returns the current options, a running match keeps the options
+// it started with so the returned value must never be modified
+func (s *Srv) GetOptions() *MatchOptions {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.opt
+}
+
+func (s *Srv) getSurvey() *Survey {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.survey
+}
+
+func (s *Srv) getAchievements() *Achievements {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.achievements
+}
+
 // http interface
 
 func (s *Srv) AddTeam(c echo.Context) error {
 	count, _ := strconv.Atoi(c.FormValue("count"))
 	mode := c.FormValue("mode")
-	id := s.queue.AddTeamToQueue(count, mode)
+	profile := c.FormValue("profile")
+	if max := s.GetOptions().MaxTeamSize; count < 1 || count > max {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": fmt.Sprintf("team size must be between 1 and %v", max)})
+	}
+	if _, err := s.GetOptions().WithProfile(profile); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+	}
+	id := s.queue.AddTeamToQueue(count, mode, profile)
 	d := map[string]interface{}{"id": id}
 	return c.JSON(http.StatusOK, d)
 }
 
+func (s *Srv) GetProfiles(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.GetOptions().ProfileNames())
+}
+
+func (s *Srv) GetModes(c echo.Context) error {
+	return c.JSON(http.StatusOK, GameModes())
+}
+
 func (s *Srv) ResetQueue(c echo.Context) error {
 	id := s.queue.ResetQueue()
 	d := map[string]interface{}{"id": id}
 	return c.JSON(http.StatusOK, d)
 }
 
+// GetHistory returns the latest matches, ?profile=name only returns matches
+// of that profile and ?profile=default the ones played without a profile
 func (s *Srv) GetHistory(c echo.Context) error {
-	d := s.db.getHistory(12)
+	var d []MatchData
+	switch profile := c.QueryParam("profile"); profile {
+	case "":
+		d = s.db.getHistory(12, s.arena.Name)
+	case defaultProfileName:
+		d = s.db.getProfileHistory(12, s.arena.Name, "")
+	default:
+		d = s.db.getProfileHistory(12, s.arena.Name, profile)
+	}
 	return c.JSON(http.StatusOK, d)
 }
 
@@ -108,7 +195,7 @@ func (s *Srv) MatchStopAnswer(c echo.Context) error {
 }
 
 func (s *Srv) GetSurvey(c echo.Context) error {
-	return c.JSON(http.StatusOK, GetSurvey())
+	return c.JSON(http.StatusOK, s.getSurvey())
 }
 
 func (s *Srv) UpdateQuestionInfo(c echo.Context) error {
@@ -132,11 +219,11 @@ func (s *Srv) UpdateMatchData(c echo.Context) error {
 }
 
 func (s *Srv) GetMainArduinoList(c echo.Context) error {
-	return c.JSON(http.StatusOK, GetOptions().MainArduinoInfo)
+	return c.JSON(http.StatusOK, s.GetOptions().MainArduinoInfo)
 }
 
 func (s *Srv) GetAnsweringMatchData(c echo.Context) error {
-	d := s.db.getAnsweringMatchData()
+	d := s.db.getAnsweringMatchData(s.arena.Name)
 	ret := make(map[string]interface{})
 	if d == nil {
 		ret["code"] = 1
@@ -178,7 +265,7 @@ func (s *Srv) listenTcp(address string) {
 			log.Println("tcp listen error: ", err.Error())
 		} else {
 			log.Printf("got new tcp connection:%v\n", conn.RemoteAddr())
-			go s.inbox.ListenConnection(NewInboxTcpConnection(conn))
+			go s.inbox.ListenConnection(NewInboxTcpConnection(conn, s.arena.Name))
 		}
 	}
 }
@@ -195,7 +282,7 @@ func (s *Srv) listenUdp(address string) {
 		os.Exit(1)
 	}
 	log.Println("listen udp:", address)
-	s.inbox.ListenConnection(NewInboxUdpConnection(conn))
+	s.inbox.ListenConnection(NewInboxUdpConnection(conn, s.arena.Name))
 }
 
 func (s *Srv) onInboxMessageArrived(msg *InboxMessage) {
@@ -208,7 +295,7 @@ func (s *Srv) onMatchEvent(evt MatchEvent) {
 
 func (s *Srv) onQueueUpdated(queueData []Team) {
 	s.sendMsgs("HallData", queueData, InboxAddressTypeAdminDevice)
-	history := s.db.getHistory(3)
+	history := s.db.getHistory(3, s.arena.Name)
 	msg := NewInboxMessage()
 	msg.SetCmd("matchData")
 	data := make(map[string]interface{})
@@ -222,6 +309,8 @@ func (s *Srv) handleMatchEvent(evt MatchEvent) {
 	switch evt.Type {
 	case MatchEventTypeEnd:
 		delete(s.mDict, evt.ID)
+		s.endState(evt.ID)
+		takeover := s.arena.release(evt.ID)
 		for _, p := range s.pDict {
 			if p.MatchID == evt.ID {
 				p.MatchID = 0
@@ -229,16 +318,33 @@ func (s *Srv) handleMatchEvent(evt MatchEvent) {
 		}
 		d := evt.Data.(map[string]interface{})
 		d["matchID"] = evt.ID
-		s.queue.TeamFinishMatch(d["teamID"].(string))
-		s.db.saveOrDelMatchData(d["matchData"].(*MatchData))
+		md := d["matchData"].(*MatchData)
+		if _, ok := GetGameMode(md.Mode).(unsavedMode); ok {
+			s.queue.TeamFinishPractice(d["teamID"].(string))
+			s.db.deleteMatchData(md)
+		} else {
+			s.queue.TeamFinishMatch(d["teamID"].(string))
+			s.getAchievements().Evaluate(md)
+			s.db.saveOrDelMatchData(md)
+		}
 		s.sendMsgs("matchStop", d, InboxAddressTypeSimulatorDevice, InboxAddressTypeAdminDevice, InboxAddressTypeIngameDevice, InboxAddressTypeQueueDevice)
+		if takeover != nil {
+			s.startPendingMatch(takeover)
+		}
 	case MatchEventTypeUpdate:
-		s.sendMsgs("updateMatch", evt.Data, InboxAddressTypeSimulatorDevice, InboxAddressTypeAdminDevice, InboxAddressTypeIngameDevice, InboxAddressTypeQueueDevice)
+		// ingame devices and the subscribers of the state feed get the
+		// match from stateSnapshot and stateDelta instead
+		s.sendMsgsExcept("updateMatch", evt.Data, s.states.addresses(), InboxAddressTypeSimulatorDevice, InboxAddressTypeAdminDevice, InboxAddressTypeQueueDevice)
+	case MatchEventTypeState:
+		s.publishState(evt.Data.(*matchState))
 	}
 }
 
 func (s *Srv) handleInboxMessage(msg *InboxMessage) {
 	shouldUpdatePlayerController := false
+	if msg.RemoveAddress != nil {
+		s.unsubscribeState(*msg.RemoveAddress)
+	}
 	if msg.RemoveAddress != nil && msg.RemoveAddress.Type.IsPlayerControllerType() {
 		cid := msg.RemoveAddress.String()
 		if pc, ok := s.pDict[cid]; ok {
@@ -299,6 +405,15 @@ func (s *Srv) handleInboxMessage(msg *InboxMessage) {
 		log.Printf("message has no cmd:%v\n", msg.Data)
 		return
 	}
+	// any screen can stream the match state
+	switch cmd {
+	case "subscribeState":
+		s.subscribeState(msg)
+		return
+	case "unsubscribeState":
+		s.unsubscribeState(*msg.Address)
+		return
+	}
 	switch msg.Address.Type {
 	case InboxAddressTypeSimulatorDevice:
 		s.handleSimulatorMessage(msg)
@@ -329,7 +444,7 @@ func (s *Srv) handleQueueMessage(msg *InboxMessage) {
 
 func (s *Srv) handleWearableMessage(msg *InboxMessage) {
 	msg.SetCmd("wearableLoc")
-	for _, m := range s.mDict {
+	if m := s.currentMatch(); m != nil {
 		m.OnMatchCmdArrived(msg)
 	}
 }
@@ -349,7 +464,7 @@ func (s *Srv) handleArduinoMessage(msg *InboxMessage) {
 			controller.ScoreUpdated = true
 		}
 	case "upload_score":
-		for _, m := range s.mDict {
+		if m := s.currentMatch(); m != nil {
 			m.OnMatchCmdArrived(msg)
 		}
 	case "hb":
@@ -374,7 +489,7 @@ func (s *Srv) handleArduinoMessage(msg *InboxMessage) {
 		}
 		switch s.adminMode {
 		case AdminModeNormal:
-			for _, m := range s.mDict {
+			if m := s.currentMatch(); m != nil {
 				m.OnLaserInfoArrived(msg)
 			}
 		case AdminModeDebug:
@@ -413,7 +528,7 @@ func (s *Srv) handleSimulatorMessage(msg *InboxMessage) {
 	switch cmd {
 	case "init":
 		d := map[string]interface{}{
-			"options": GetOptions(),
+			"options": s.GetOptions(),
 			"ID":      msg.Address.String(),
 		}
 		s.sendMsgToAddresses("init", d, []InboxAddress{*msg.Address})
@@ -425,8 +540,8 @@ func (s *Srv) handleSimulatorMessage(msg *InboxMessage) {
 				ids = append(ids, pc.ID)
 			}
 		}
-		s.startNewMatch(ids, mode, "")
-	case "stopMatch", "playerMove", "playerStop":
+		s.startNewMatch(ids, mode, msg.GetStr("profile"), "")
+	case "stopMatch", "pauseMatch", "resumeMatch", "playerMove", "playerStop":
 		mid := uint(msg.Get("matchID").(float64))
 		if match := s.mDict[mid]; match != nil {
 			match.OnMatchCmdArrived(msg)
@@ -463,8 +578,12 @@ func (s *Srv) handleAdminMessage(msg *InboxMessage) {
 		s.queue.TeamQueryData()
 	case "queryControllerData":
 		s.sendMsg("ControllerData", s.getControllerData(), msg.Address.ID, msg.Address.Type)
+	case "queryArena":
+		s.sendMsg("ArenaStatus", s.arena.status(), msg.Address.ID, msg.Address.Type)
+	case "queryModes":
+		s.sendMsg("ModeList", GameModes(), msg.Address.ID, msg.Address.Type)
 	case "queryQuestionCount":
-		s.sendMsg("QuestionCount", len(GetSurvey().Questions), msg.Address.ID, msg.Address.Type)
+		s.sendMsg("QuestionCount", len(s.getSurvey().Questions), msg.Address.ID, msg.Address.Type)
 	case "teamCutLine":
 		teamID := msg.GetStr("teamID")
 		s.queue.TeamCutLine(teamID)
@@ -474,13 +593,30 @@ func (s *Srv) handleAdminMessage(msg *InboxMessage) {
 	case "teamChangeMode":
 		teamID := msg.GetStr("teamID")
 		mode := msg.GetStr("mode")
+		// both are checked first so that a rejected change changes nothing
+		if GetGameMode(mode) == nil {
+			s.sendToOne(NewErrorInboxMessage(fmt.Sprintf("未知的模式%v", mode)), *msg.Address)
+			return
+		}
+		profile, hasProfile := msg.Get("profile").(string)
+		if hasProfile {
+			if _, err := s.GetOptions().WithProfile(profile); err != nil {
+				s.sendToOne(NewErrorInboxMessage(err.Error()), *msg.Address)
+				return
+			}
+		}
 		s.queue.TeamChangeMode(teamID, mode)
+		if hasProfile {
+			s.queue.TeamChangeProfile(teamID, profile)
+		}
 	case "teamDelay":
 		teamID := msg.GetStr("teamID")
 		s.queue.TeamDelay(teamID)
 	case "teamAddPlayer":
 		teamID := msg.GetStr("teamID")
-		s.queue.TeamAddPlayer(teamID)
+		if err := s.queue.TeamAddPlayer(teamID); err != nil {
+			s.sendToOne(NewErrorInboxMessage(err.Error()), *msg.Address)
+		}
 	case "teamRemovePlayer":
 		teamID := msg.GetStr("teamID")
 		s.queue.TeamRemovePlayer(teamID)
@@ -495,8 +631,17 @@ func (s *Srv) handleAdminMessage(msg *InboxMessage) {
 		mode := msg.GetStr("mode")
 		ids := msg.Get("ids").(string)
 		controllerIDs := strings.Split(ids, ",")
-		s.queue.TeamStart(teamID)
-		s.startNewMatch(controllerIDs, mode, teamID)
+		p := &pendingMatch{controllerIDs, mode, s.queue.TeamProfile(teamID), teamID}
+		if s.arena.busy() {
+			// force stops the match on the arena and starts this one after it
+			if force, _ := msg.Get("force").(bool); force {
+				s.takeOverArena(p)
+			} else {
+				s.sendToOne(NewErrorInboxMessage(s.arena.busyError()), *msg.Address)
+			}
+			return
+		}
+		s.startPendingMatch(p)
 	case "teamCall":
 		teamID := msg.GetStr("teamID")
 		s.queue.TeamCall(teamID)
@@ -507,7 +652,7 @@ func (s *Srv) handleAdminMessage(msg *InboxMessage) {
 		am.Set("mode", mode)
 		log.Printf("send mode change:%v\n", mode)
 		if mode == "3" {
-			s.bgControl(GetOptions().BgIdle)
+			s.bgControl(s.GetOptions().BgIdle)
 		}
 		s.sends(am, InboxAddressTypeMainArduinoDevice, InboxAddressTypeSubArduinoDevice, InboxAddressTypeDoorArduino, InboxAddressTypeMusicArduino)
 		s.sendMsgs("reset", nil, InboxAddressTypeIngameDevice)
@@ -519,11 +664,13 @@ func (s *Srv) handleAdminMessage(msg *InboxMessage) {
 			i += 1
 		}
 		s.sendMsg("ArduinoList", arduinolist, msg.Address.ID, msg.Address.Type)
-	case "stopMatch":
+	case "stopMatch", "pauseMatch", "resumeMatch":
 		mid := uint(msg.Get("matchID").(float64))
 		if match := s.mDict[mid]; match != nil {
 			match.OnMatchCmdArrived(msg)
 		}
+	case "adjustMatch":
+		s.adjustMatch(msg)
 	case "laserOn":
 		s.adminMode = AdminModeDebug
 		id := msg.GetStr("id")
@@ -552,10 +699,10 @@ func (s *Srv) handleAdminMessage(msg *InboxMessage) {
 		s.laserControl(id, idx, false)
 	case "stopListenLaser":
 		s.adminMode = AdminModeNormal
-		GetLaserPair().Save()
+		s.laserPair.Save()
 	case "recordLaser":
 		key := msg.GetStr("from") + ":" + msg.GetStr("from_idx")
-		GetLaserPair().Record(key, msg.GetStr("to"), msg.GetStr("to_idx"), 1)
+		s.laserPair.Record(key, msg.GetStr("to"), msg.GetStr("to_idx"), 1)
 	case "startQuickCheck":
 		if s.qc == nil {
 			s.qc = NewQuickChecker(s)
@@ -576,11 +723,41 @@ func (s *Srv) handleAdminMessage(msg *InboxMessage) {
 			return
 		}
 		s.qc.Query()
+	case "reloadConfig":
+		if _, err := s.ReloadConfig(); err != nil {
+			s.sendToOne(NewErrorInboxMessage(err.Error()), *msg.Address)
+		}
 	}
 }
 
-func (s *Srv) startNewMatch(controllerIDs []string, mode string, teamID string) {
+func (s *Srv) startNewMatch(controllerIDs []string, mode string, profile string, teamID string) {
+	if s.arena.busy() {
+		s.sends(NewErrorInboxMessage(s.arena.busyError()), InboxAddressTypeAdminDevice)
+		return
+	}
+	gm := GetGameMode(mode)
+	if gm == nil {
+		s.sends(NewErrorInboxMessage(fmt.Sprintf("未知的模式%v", mode)), InboxAddressTypeAdminDevice)
+		return
+	}
+	if c, ok := gm.(memberChecker); ok {
+		if err := c.CheckMembers(len(controllerIDs)); err != nil {
+			s.sends(NewErrorInboxMessage(err.Error()), InboxAddressTypeAdminDevice)
+			return
+		}
+	}
+	opt, err := s.GetOptions().WithProfile(profile)
+	if err != nil {
+		s.sends(NewErrorInboxMessage(err.Error()), InboxAddressTypeAdminDevice)
+		return
+	}
+	if len(controllerIDs) > opt.MaxTeamSize {
+		s.sends(NewErrorInboxMessage(fmt.Sprintf("队伍最多%v人", opt.MaxTeamSize)), InboxAddressTypeAdminDevice)
+		return
+	}
 	md := s.db.newMatch()
+	md.Seed = s.clock.Now().UnixNano()
+	md.Arena = s.arena.Name
 	mid := md.ID
 	for _, id := range controllerIDs {
 		if p, ok := s.pDict[id]; ok {
@@ -591,8 +768,9 @@ func (s *Srv) startNewMatch(controllerIDs []string, mode string, teamID string)
 			return
 		}
 	}
-	m := NewMatch(s, controllerIDs, md, mode, teamID, s.isSimulator)
+	m := NewMatch(s, opt, controllerIDs, md, mode, teamID, s.isSimulator)
 	s.mDict[mid] = m
+	s.arena.acquire(mid)
 	go m.Run()
 	s.sendMsgs("newMatch", mid, InboxAddressTypeAdminDevice, InboxAddressTypeSimulatorDevice)
 }
@@ -620,6 +798,19 @@ func (s *Srv) sendMsgs(cmd string, data interface{}, types ...InboxAddressType)
 	s.sendMsgToAddresses(cmd, data, addrs)
 }
 
+// sendMsgsExcept is sendMsgs but skips the devices of except
+func (s *Srv) sendMsgsExcept(cmd string, data interface{}, except []InboxAddress, types ...InboxAddressType) {
+	addrs := make([]InboxAddress, len(types))
+	for i, t := range types {
+		addrs[i] = InboxAddress{t, ""}
+	}
+	msg := NewInboxMessage()
+	msg.SetCmd(cmd)
+	msg.Set("data", data)
+	s.recordOutbound(msg, addrs)
+	s.inbox.SendExcept(s.arena.Name, msg, addrs, except)
+}
+
 func (s *Srv) sendMsgToAddresses(cmd string, data interface{}, addrs []InboxAddress) {
 	msg := NewInboxMessage()
 	msg.SetCmd(cmd)
@@ -675,7 +866,7 @@ func (s *Srv) doorControl(IL string, OL string, ID string) {
 func (s *Srv) lasersControl(large []int, small []int) {
 	largeAddrs := make([]InboxAddress, 0)
 	smallAddrs := make([]InboxAddress, 0)
-	for _, info := range GetOptions().MainArduinoInfo {
+	for _, info := range s.GetOptions().MainArduinoInfo {
 		if info.LaserNum == 5 {
 			largeAddrs = append(largeAddrs, InboxAddress{InboxAddressTypeMainArduinoDevice, info.ID})
 		} else {
@@ -717,7 +908,7 @@ func (s *Srv) lasersControl(large []int, small []int) {
 }
 
 func (s *Srv) laserControl(ID string, idx int, openOrClose bool) {
-	valid := GetLaserPair().IsValid(ID, idx)
+	valid := s.laserPair.IsValid(ID, idx)
 	if !valid && openOrClose {
 		return
 	}
@@ -798,7 +989,7 @@ func (s *Srv) ledControlByAddresses(mode string, addrs []InboxAddress) {
 }
 
 func (s *Srv) ledControlByCell(x int, y int, mode string) {
-	ids := GetOptions().mainArduinosByPos(x, y)
+	ids := s.GetOptions().mainArduinosByPos(x, y)
 	if len(ids) == 0 {
 		return
 	}
@@ -829,7 +1020,7 @@ func (s *Srv) bgControl(music string) {
 }
 
 func (s *Srv) musicControlByCell(x int, y int, music string) {
-	ids := GetOptions().mainArduinosByPos(x, y)
+	ids := s.GetOptions().mainArduinosByPos(x, y)
 	if len(ids) == 0 {
 		return
 	}
@@ -844,7 +1035,7 @@ func (s *Srv) musicControlByCell(x int, y int, music string) {
 }
 
 func (s *Srv) ledFlowEffect() {
-	opt := GetOptions()
+	opt := s.GetOptions()
 	ledList := make([]map[string]string, 3)
 	ledList[0] = map[string]string{"wall": "M", "led_t": "2", "mode": "1"}  // M2常亮
 	ledList[1] = map[string]string{"wall": "M", "led_t": "3", "mode": "1"}  // M3常亮
@@ -882,7 +1073,7 @@ func (s *Srv) ledRampageEffect(offs map[string]bool) {
 	addrsB := make([]InboxAddress, 0)
 	addrsOff := make([]InboxAddress, len(offs))
 	idx := 0
-	for _, info := range GetOptions().MainArduinoInfo {
+	for _, info := range s.GetOptions().MainArduinoInfo {
 		if _, ok := offs[info.ID]; ok {
 			addrsOff[idx] = InboxAddress{InboxAddressTypeMainArduinoDevice, info.ID}
 			idx += 1
@@ -913,7 +1104,8 @@ func (s *Srv) ledRampageEffect(offs map[string]bool) {
 }
 
 func (s *Srv) send(msg *InboxMessage, addrs []InboxAddress) {
-	s.inbox.Send(msg, addrs)
+	s.recordOutbound(msg, addrs)
+	s.inbox.Send(s.arena.Name, msg, addrs)
 }
 
 func (s *Srv) sendToOne(msg *InboxMessage, addr InboxAddress) {
@@ -921,22 +1113,22 @@ func (s *Srv) sendToOne(msg *InboxMessage, addr InboxAddress) {
 }
 
 func (s *Srv) initArduinoControllers() {
-	for _, main := range GetOptions().MainArduino {
+	for _, main := range s.GetOptions().MainArduino {
 		addr := InboxAddress{InboxAddressTypeMainArduinoDevice, main}
 		controller := NewArduinoController(addr)
 		s.aDict[addr.String()] = controller
 	}
-	for _, sub := range GetOptions().SubArduino {
+	for _, sub := range s.GetOptions().SubArduino {
 		addr := InboxAddress{InboxAddressTypeSubArduinoDevice, sub}
 		controller := NewArduinoController(addr)
 		s.aDict[addr.String()] = controller
 	}
-	for _, music := range GetOptions().MusicArduino {
+	for _, music := range s.GetOptions().MusicArduino {
 		addr := InboxAddress{InboxAddressTypeMusicArduino, music}
 		controller := NewArduinoController(addr)
 		s.aDict[addr.String()] = controller
 	}
-	for _, door := range GetOptions().DoorArduino {
+	for _, door := range s.GetOptions().DoorArduino {
 		addr := InboxAddress{InboxAddressTypeDoorArduino, door}
 		controller := NewArduinoController(addr)
 		s.aDict[addr.String()] = controller
@@ -947,13 +1139,17 @@ func (s *Srv) updateArduinoControllerScore(controller *ArduinoController) {
 	if !controller.NeedUpdateScore() {
 		return
 	}
-	scoreInfo := GetScoreInfo()
+	s.send(s.scoreInfoMessage(), []InboxAddress{controller.Address})
+}
+
+func (s *Srv) scoreInfoMessage() *InboxMessage {
+	opt := s.GetOptions()
 	msg := NewInboxMessage()
 	msg.SetCmd("init_score")
-	msg.Set("score", scoreInfo)
-	msg.Set("upload_time", strconv.Itoa(GetOptions().UploadTime))
-	msg.Set("heartbeat_time", strconv.Itoa(GetOptions().HeartbeatTime))
-	msg.Set("s_upload_time", strconv.Itoa(GetOptions().SubUploadTime))
-	msg.Set("s_heartbeat_time", strconv.Itoa(GetOptions().SubHeartbeatTime))
-	s.send(msg, []InboxAddress{controller.Address})
+	msg.Set("score", opt.ScoreInfo())
+	msg.Set("upload_time", strconv.Itoa(opt.UploadTime))
+	msg.Set("heartbeat_time", strconv.Itoa(opt.HeartbeatTime))
+	msg.Set("s_upload_time", strconv.Itoa(opt.SubUploadTime))
+	msg.Set("s_heartbeat_time", strconv.Itoa(opt.SubHeartbeatTime))
+	return msg
 }
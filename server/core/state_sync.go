@@ -0,0 +1,302 @@
+package core
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var _ = log.Printf
+
+const (
+	defaultStateRate = 10
+	// a match ticks every matchTickInterval, more is never sent
+	maxStateRate = 30
+)
+
+// matchState is the state of a match at one tick, flat maps every leaf of the
+// json of the match by its json pointer, like /member/0/gold. raw is only
+// marshaled when a subscriber waits for a snapshot.
+type matchState struct {
+	matchID uint
+	raw     json.RawMessage
+	flat    map[string]interface{}
+}
+
+type stateSubscriber struct {
+	addr     InboxAddress
+	interval time.Duration
+	next     time.Time
+	seq      int
+	last     *matchState // as the subscriber has it, nil before a snapshot
+}
+
+// stateFeed streams the running match to the devices that subscribed with
+// subscribeState: a stateSnapshot first, then a stateDelta with the leaves
+// that changed at most rate times a second
+type stateFeed struct {
+	interval int64 // of the fastest subscriber in nanoseconds, 0 without any
+	snapshot int32 // 1 when a subscriber waits for a snapshot
+	subs     map[string]*stateSubscriber
+	current  *matchState
+}
+
+func newStateFeed() *stateFeed {
+	return &stateFeed{subs: make(map[string]*stateSubscriber)}
+}
+
+// wanted is how often a match has to publish its state
+func (f *stateFeed) wanted() time.Duration {
+	return time.Duration(atomic.LoadInt64(&f.interval))
+}
+
+func (f *stateFeed) updateInterval() {
+	var fastest time.Duration
+	for _, sub := range f.subs {
+		if fastest == 0 || sub.interval < fastest {
+			fastest = sub.interval
+		}
+	}
+	atomic.StoreInt64(&f.interval, int64(fastest))
+}
+
+// wantSnapshot makes the match marshal itself at its next state
+func (f *stateFeed) wantSnapshot() {
+	atomic.StoreInt32(&f.snapshot, 1)
+}
+
+// takeSnapshot is true once for every wantSnapshot
+func (f *stateFeed) takeSnapshot() bool {
+	return atomic.CompareAndSwapInt32(&f.snapshot, 1, 0)
+}
+
+// addresses are the subscribers, they get the match from the feed only
+func (f *stateFeed) addresses() []InboxAddress {
+	addrs := make([]InboxAddress, 0, len(f.subs))
+	for _, sub := range f.subs {
+		addrs = append(addrs, sub.addr)
+	}
+	return addrs
+}
+
+func (s *Srv) subscribeState(msg *InboxMessage) {
+	rate := defaultStateRate
+	if r, ok := msg.Get("rate").(float64); ok && r > 0 {
+		rate = int(math.Min(math.Ceil(r), maxStateRate))
+	}
+	sub := &stateSubscriber{addr: *msg.Address}
+	sub.interval = time.Second / time.Duration(rate)
+	s.states.subs[msg.Address.String()] = sub
+	s.states.updateInterval()
+	s.states.wantSnapshot()
+	if cur := s.states.current; cur != nil && cur.raw != nil {
+		s.sendState(sub, cur, s.clock.Now())
+	}
+}
+
+func (s *Srv) unsubscribeState(addr InboxAddress) {
+	if _, ok := s.states.subs[addr.String()]; !ok {
+		return
+	}
+	delete(s.states.subs, addr.String())
+	s.states.updateInterval()
+}
+
+// publishState hands the state to every subscriber whose time has come
+func (s *Srv) publishState(st *matchState) {
+	s.states.current = st
+	now := s.clock.Now()
+	for _, sub := range s.states.subs {
+		if now.Before(sub.next) {
+			continue
+		}
+		s.sendState(sub, st, now)
+	}
+}
+
+func (s *Srv) sendState(sub *stateSubscriber, st *matchState, now time.Time) {
+	snapshot := sub.last == nil || sub.last.matchID != st.matchID
+	if snapshot && st.raw == nil {
+		// the match marshals itself at the next state
+		s.states.wantSnapshot()
+		return
+	}
+	sub.next = sub.next.Add(sub.interval)
+	if sub.next.Before(now) {
+		sub.next = now.Add(sub.interval)
+	}
+	if snapshot {
+		sub.seq = 0
+		d := map[string]interface{}{
+			"matchID": st.matchID,
+			"seq":     sub.seq,
+			"state":   st.raw,
+		}
+		s.sendMsgToAddresses("stateSnapshot", d, []InboxAddress{sub.addr})
+		sub.last = st
+		return
+	}
+	set, del := diffState(sub.last.flat, st.flat)
+	if len(set) == 0 && len(del) == 0 {
+		return
+	}
+	sub.seq += 1
+	d := map[string]interface{}{
+		"matchID": st.matchID,
+		"seq":     sub.seq,
+		"set":     set,
+		"del":     del,
+	}
+	s.sendMsgToAddresses("stateDelta", d, []InboxAddress{sub.addr})
+	sub.last = st
+}
+
+// endState makes every subscriber start the next match with a snapshot
+func (s *Srv) endState(mid uint) {
+	if s.states.current != nil && s.states.current.matchID == mid {
+		s.states.current = nil
+	}
+	for _, sub := range s.states.subs {
+		sub.last = nil
+		sub.next = time.Time{}
+	}
+	if len(s.states.subs) > 0 {
+		s.states.wantSnapshot()
+	}
+}
+
+// publishState builds the state of the match once the fastest subscriber
+// wants a new one from the tracked leaves, the match is only marshaled for
+// a snapshot
+func (m *Match) publishState() {
+	wanted := m.srv.states.wanted()
+	if wanted <= 0 {
+		m.stateRemain = 0
+		return
+	}
+	m.stateRemain -= matchTickInterval
+	if m.stateRemain > 0 {
+		return
+	}
+	m.stateRemain += wanted
+	if m.stateRemain <= 0 {
+		m.stateRemain = wanted
+	}
+	st := &matchState{matchID: m.ID, flat: make(map[string]interface{})}
+	m.trackState(st.flat)
+	if m.srv.states.takeSnapshot() {
+		b, err := json.Marshal(m)
+		if err != nil {
+			log.Printf("marshal match state error:%v\n", err)
+			m.srv.states.wantSnapshot()
+		}
+		st.raw = b
+	}
+	m.srv.onMatchEvent(MatchEvent{MatchEventTypeState, m.ID, st})
+}
+
+// flattenState keeps the leaves of v by their json pointer, an empty object
+// or array is a leaf so that it still exists for the client
+func flattenState(path string, v interface{}, flat map[string]interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 {
+			flat[path] = t
+		}
+		for k, c := range t {
+			flattenState(path+"/"+escapePointer(k), c, flat)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			flat[path] = t
+		}
+		for i, c := range t {
+			flattenState(path+"/"+strconv.Itoa(i), c, flat)
+		}
+	default:
+		flat[path] = v
+	}
+}
+
+func escapePointer(k string) string {
+	return strings.Replace(strings.Replace(k, "~", "~0", -1), "/", "~1", -1)
+}
+
+// diffState is what turns from into to: the leaves to set and the pointers
+// to delete, a client applies del before set
+func diffState(from map[string]interface{}, to map[string]interface{}) (map[string]interface{}, []string) {
+	set := make(map[string]interface{})
+	gone := make([]string, 0)
+	for k, v := range to {
+		if old, ok := from[k]; !ok || !sameLeaf(old, v) {
+			set[k] = v
+		}
+	}
+	for k := range from {
+		if _, ok := to[k]; !ok {
+			gone = append(gone, k)
+		}
+	}
+	return set, collapseDeleted(gone, to)
+}
+
+// collapseDeleted deletes a leaf that is gone at its topmost container with
+// nothing left in to, so that a removed laser leaves no empty object behind
+func collapseDeleted(gone []string, to map[string]interface{}) []string {
+	del := make([]string, 0)
+	if len(gone) == 0 {
+		return del
+	}
+	live := make(map[string]bool)
+	for k := range to {
+		for i := len(k) - 1; i > 0; i-- {
+			if k[i] == '/' {
+				if live[k[:i]] {
+					break
+				}
+				live[k[:i]] = true
+			}
+		}
+	}
+	deleted := make(map[string]bool)
+	for _, k := range gone {
+		p := k
+		for i := 1; i < len(k); i++ {
+			if k[i] != '/' {
+				continue
+			}
+			if _, ok := to[k[:i]]; !ok && !live[k[:i]] {
+				p = k[:i]
+				break
+			}
+		}
+		if live[p] || deleted[p] {
+			continue
+		}
+		deleted[p] = true
+		del = append(del, p)
+	}
+	sort.Strings(del)
+	return del
+}
+
+func sameLeaf(a interface{}, b interface{}) bool {
+	switch a.(type) {
+	case map[string]interface{}:
+		_, ok := b.(map[string]interface{})
+		return ok
+	case []interface{}:
+		_, ok := b.([]interface{})
+		return ok
+	}
+	switch b.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return a == b
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// marshaledState is the flat state of the json of m, as the deltas used to
+// be built
+func marshaledState(t *testing.T, m *Match) map[string]interface{} {
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshal match error:%v", err)
+	}
+	var v interface{}
+	json.Unmarshal(b, &v)
+	flat := make(map[string]interface{})
+	flattenState("", v, flat)
+	return flat
+}
+
+// trackedState is the flat state of trackState with the leaves as json
+// gives them back
+func trackedState(t *testing.T, m *Match) map[string]interface{} {
+	flat := make(map[string]interface{})
+	m.trackState(flat)
+	b, err := json.Marshal(flat)
+	if err != nil {
+		t.Fatalf("marshal tracked state error:%v", err)
+	}
+	v := make(map[string]interface{})
+	json.Unmarshal(b, &v)
+	return v
+}
+
+func TestStateTrackingMatchesJSON(t *testing.T) {
+	tests := []struct {
+		mode      string
+		simulator bool
+	}{
+		{"g", true},
+		{"g", false}, // Laser instead of SimuLaser
+		{"s", true},
+		{"v", true},
+		{"p", true},
+	}
+	for _, tt := range tests {
+		mode := tt.mode
+		clock := NewManualClock(time.Unix(1000, 0))
+		s := newTestSrv(t, clock)
+		md := MatchData{Seed: 42}
+		m := NewMatch(s, s.GetOptions(), []string{"1", "2"}, &md, mode, "", tt.simulator)
+		m.Start()
+		ticks := int(60 * time.Second / matchTickInterval)
+		for i := 0; i < ticks; i++ {
+			if i%100 == 0 {
+				if got, want := trackedState(t, m), marshaledState(t, m); !reflect.DeepEqual(got, want) {
+					t.Fatalf("mode %v, simulator %v, stage %v: tracked state differs from json\n%v\n%v", mode, tt.simulator, m.Stage, got, want)
+				}
+			}
+			if !m.Step() {
+				break
+			}
+			clock.Advance(matchTickInterval)
+		}
+	}
+}
+
+func TestDiffStateDeletesRemovedContainers(t *testing.T) {
+	from := map[string]interface{}{
+		"/lasers/0/isPause":   true,
+		"/lasers/1/isPause":   false,
+		"/lasers/1/pos/X":     1.0,
+		"/onButtons/1-1":      true,
+		"/pausedStage":        "ongoing",
+		"/practice/presses":   []interface{}{},
+		"/member/0/levelData": []interface{}{},
+	}
+	to := map[string]interface{}{
+		"/lasers/0/isPause":      false,
+		"/onButtons":             map[string]interface{}{},
+		"/practice/presses/0/at": 1.0,
+		"/member/0/levelData":    []interface{}{},
+	}
+	set, del := diffState(from, to)
+	wantSet := map[string]interface{}{
+		"/lasers/0/isPause":      false,
+		"/onButtons":             map[string]interface{}{},
+		"/practice/presses/0/at": 1.0,
+	}
+	if !reflect.DeepEqual(set, wantSet) {
+		t.Errorf("set %v, want %v", set, wantSet)
+	}
+	wantDel := []string{"/lasers/1", "/onButtons/1-1", "/pausedStage"}
+	if !reflect.DeepEqual(del, wantDel) {
+		t.Errorf("del %v, want %v", del, wantDel)
+	}
+}
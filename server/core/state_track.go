@@ -0,0 +1,173 @@
+package core
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+)
+
+var _ = log.Printf
+
+// the leaves of a match are put into the flat state by hand so that a
+// stateDelta doesn't marshal the whole match, every json tag of the match
+// and of what it holds has to be tracked here as well
+
+// stateTracker puts its leaves into flat under path, a laser type without it
+// is marshaled on its own
+type stateTracker interface {
+	trackState(path string, flat map[string]interface{})
+}
+
+func (m *Match) trackState(flat map[string]interface{}) {
+	if m.Member == nil {
+		flat["/member"] = nil
+	} else if len(m.Member) == 0 {
+		flat["/member"] = []interface{}{}
+	}
+	for i, player := range m.Member {
+		player.trackState("/member/"+strconv.Itoa(i), flat)
+	}
+	flat["/stage"] = m.Stage
+	flat["/totalTime"] = m.TotalTime
+	flat["/elasped"] = m.Elasped
+	flat["/warmupTime"] = m.WarmupTime
+	flat["/rampageTime"] = m.RampageTime
+	flat["/mode1MaxTime"] = m.Mode1MaxTime
+	flat["/mode"] = m.Mode
+	flat["/profile"] = m.Profile
+	flat["/gold"] = m.Gold
+	flat["/energy"] = m.Energy
+	if m.OnButtons == nil {
+		flat["/onButtons"] = nil
+	} else if len(m.OnButtons) == 0 {
+		flat["/onButtons"] = map[string]interface{}{}
+	}
+	for k, v := range m.OnButtons {
+		flat["/onButtons/"+escapePointer(k)] = v
+	}
+	flat["/rampageCount"] = m.RampageCount
+	if m.Lasers == nil {
+		flat["/lasers"] = nil
+	} else if len(m.Lasers) == 0 {
+		flat["/lasers"] = []interface{}{}
+	}
+	for i, l := range m.Lasers {
+		trackLaser("/lasers/"+strconv.Itoa(i), l, flat)
+	}
+	flat["/id"] = m.ID
+	flat["/teamID"] = m.TeamID
+	flat["/maxEnergy"] = m.MaxEnergy
+	flat["/maxRampageTime"] = m.MaxRampageTime
+	flat["/isSimulator"] = m.IsSimulator
+	for i, side := range m.Sides {
+		side.trackState("/sides/"+strconv.Itoa(i), flat)
+	}
+	if m.PausedStage != "" {
+		flat["/pausedStage"] = m.PausedStage
+	}
+	if m.PowerUps == nil {
+		flat["/powerUps"] = nil
+	} else if len(m.PowerUps) == 0 {
+		flat["/powerUps"] = map[string]interface{}{}
+	}
+	for k, v := range m.PowerUps {
+		flat["/powerUps/"+escapePointer(k)] = v
+	}
+	for k, v := range m.DoubleGold {
+		flat["/doubleGold/"+strconv.Itoa(k)] = v
+	}
+	if m.Practice != nil {
+		m.Practice.trackState("/practice", flat)
+	}
+}
+
+func trackLaser(path string, l LaserInterface, flat map[string]interface{}) {
+	if t, ok := l.(stateTracker); ok {
+		t.trackState(path, flat)
+		return
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		log.Printf("marshal laser state error:%v\n", err)
+		return
+	}
+	var v interface{}
+	json.Unmarshal(b, &v)
+	flattenState(path, v, flat)
+}
+
+func (p RP) trackState(path string, flat map[string]interface{}) {
+	flat[path+"/X"] = p.X
+	flat[path+"/Y"] = p.Y
+}
+
+func (p *Player) trackState(path string, flat map[string]interface{}) {
+	p.Pos.trackState(path+"/pos", flat)
+	flat[path+"/dir"] = p.Direction
+	flat[path+"/button"] = p.Button
+	flat[path+"/buttonTime"] = p.ButtonTime
+	flat[path+"/buttonLevel"] = p.ButtonLevel
+	flat[path+"/gold"] = p.Gold
+	flat[path+"/energy"] = p.Energy
+	for i, v := range p.LevelData {
+		flat[path+"/levelData/"+strconv.Itoa(i)] = v
+	}
+	flat[path+"/hitCount"] = p.HitCount
+	flat[path+"/lostgold"] = p.LostGold
+	flat[path+"/invincibleTime"] = p.InvincibleTime
+	flat[path+"/combo"] = p.Combo
+	flat[path+"/comboCount"] = p.ComboCount
+	flat[path+"/cid"] = p.ControllerID
+	p.DisplayPos.trackState(path+"/displayPos", flat)
+	flat[path+"/offline"] = p.Offline
+	flat[path+"/side"] = p.Side
+	flat[path+"/powerUps"] = p.PowerUps
+	flat[path+"/maxCombo"] = p.MaxCombo
+	flat[path+"/confidence"] = p.Confidence
+}
+
+func (l *Laser) trackState(path string, flat map[string]interface{}) {
+	flat[path+"/isPause"] = l.IsPause
+	flat[path+"/warning"] = l.Warning
+	l.DisplayP.trackState(path+"/displayP", flat)
+	l.DisplayP2.trackState(path+"/displayP2", flat)
+}
+
+func (l *SimuLaser) trackState(path string, flat map[string]interface{}) {
+	l.Pos.trackState(path+"/pos", flat)
+	flat[path+"/isPause"] = l.IsPause
+	flat[path+"/isClosed"] = l.IsClosed
+}
+
+func (side *Side) trackState(path string, flat map[string]interface{}) {
+	flat[path+"/index"] = side.Index
+	flat[path+"/size"] = side.Size
+	flat[path+"/gold"] = side.Gold
+	flat[path+"/energy"] = side.Energy
+	flat[path+"/rampageTime"] = side.RampageTime
+	flat[path+"/rampageCount"] = side.RampageCount
+}
+
+func (ps *PracticeState) trackState(path string, flat map[string]interface{}) {
+	if ps.Path == nil {
+		flat[path+"/path"] = nil
+	} else if len(ps.Path) == 0 {
+		flat[path+"/path"] = []interface{}{}
+	}
+	for i, b := range ps.Path {
+		flat[path+"/path/"+strconv.Itoa(i)] = b
+	}
+	flat[path+"/current"] = ps.Current
+	if ps.Presses == nil {
+		flat[path+"/presses"] = nil
+	} else if len(ps.Presses) == 0 {
+		flat[path+"/presses"] = []interface{}{}
+	}
+	for i, press := range ps.Presses {
+		p := path + "/presses/" + strconv.Itoa(i)
+		flat[p+"/button"] = press.Button
+		flat[p+"/cid"] = press.ControllerID
+		flat[p+"/level"] = press.Level
+		flat[p+"/at"] = press.At
+	}
+}
@@ -1,9 +1,9 @@
 package core
 
 import (
+	"fmt"
 	"github.com/BurntSushi/toml"
 	"log"
-	"os"
 )
 
 var _ = log.Printf
@@ -17,17 +17,15 @@ type Survey struct {
 	Questions []SurveyQuestion `json:"questions"`
 }
 
-var survey = LoadSurvey()
-
-func GetSurvey() *Survey {
-	return survey
-}
-
-func LoadSurvey() *Survey {
+func LoadSurvey(path string) (*Survey, error) {
 	var s Survey
-	if _, err := toml.DecodeFile("survey.toml", &s); err != nil {
-		log.Printf("parse survey error:%v\n", err.Error())
-		os.Exit(1)
+	if _, err := toml.DecodeFile(path, &s); err != nil {
+		return nil, fmt.Errorf("parse survey error:%v", err.Error())
+	}
+	var errs ValidationErrors
+	errs.merge(path+":", s.Validate())
+	if len(errs) > 0 {
+		return nil, errs
 	}
-	return &s
+	return &s, nil
 }
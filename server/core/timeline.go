@@ -0,0 +1,210 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/labstack/echo"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var _ = log.Printf
+
+const (
+	timelineVersion = 1
+	// samples returned by /api/timeline when points is not given
+	defaultTimelinePoints = 120
+)
+
+// TimelineSample is the state of a match at T seconds of elasped time
+type TimelineSample struct {
+	T          float64        `json:"t"`
+	Gold       int            `json:"gold"`
+	Energy     float64        `json:"energy"`
+	Stage      string         `json:"stage"`
+	SpeedLevel int            `json:"speedLevel"`
+	Players    []PlayerSample `json:"players"` // in the order of MatchData.Member
+}
+
+type PlayerSample struct {
+	Gold int `json:"gold"`
+	Tile int `json:"tile"`
+}
+
+// sampleTimeline takes a sample every TimelineInterval seconds of an ongoing
+// match, the first one when it starts
+func (m *Match) sampleTimeline(sec float64) {
+	if m.opt.TimelineInterval <= 0 {
+		return
+	}
+	m.sampleRemain -= sec
+	if m.sampleRemain > 0 {
+		return
+	}
+	m.sampleRemain += m.opt.TimelineInterval
+	m.takeSample()
+}
+
+func (m *Match) takeSample() {
+	s := TimelineSample{}
+	s.T = m.Elasped
+	s.Gold = m.Gold
+	s.Energy = m.Energy
+	s.Stage = m.Stage
+	s.SpeedLevel = m.opt.laserSpeedLevel(m.Energy)
+	s.Players = make([]PlayerSample, len(m.Member))
+	for i, player := range m.Member {
+		s.Players[i] = PlayerSample{player.Gold, m.opt.TilePosToInt(player.tilePos)}
+	}
+	m.samples = append(m.samples, s)
+}
+
+// dumpTimeline is nil when the match took no sample
+func (m *Match) dumpTimeline() *TimelineData {
+	if len(m.samples) == 0 {
+		return nil
+	}
+	if last := m.samples[len(m.samples)-1]; last.T < m.Elasped {
+		m.takeSample()
+	}
+	return encodeTimeline(m.opt.TimelineInterval, m.samples)
+}
+
+// encodeTimeline keeps every value as a zigzag varint of its change since the
+// previous sample, stages are indexes into TimelineData.Stages. Time is in
+// milliseconds and energy in tenths, a sample of a quiet second takes about
+// 5+2*players bytes.
+func encodeTimeline(interval float64, samples []TimelineSample) *TimelineData {
+	t := TimelineData{}
+	t.Interval = interval
+	t.Count = len(samples)
+	t.Players = len(samples[0].Players)
+	stages := make([]string, 0)
+	stageIndex := make(map[string]int)
+	buf := make([]byte, binary.MaxVarintLen64)
+	data := []byte{timelineVersion}
+	put := func(v int64) {
+		n := binary.PutVarint(buf, v)
+		data = append(data, buf[:n]...)
+	}
+	prev := TimelineSample{Players: make([]PlayerSample, t.Players)}
+	prevStage := 0
+	for _, s := range samples {
+		i, ok := stageIndex[s.Stage]
+		if !ok {
+			i = len(stages)
+			stageIndex[s.Stage] = i
+			stages = append(stages, s.Stage)
+		}
+		put(int64(math.Floor(s.T*1000+0.5)) - int64(math.Floor(prev.T*1000+0.5)))
+		put(int64(s.Gold - prev.Gold))
+		put(int64(math.Floor(s.Energy*10+0.5)) - int64(math.Floor(prev.Energy*10+0.5)))
+		put(int64(i - prevStage))
+		put(int64(s.SpeedLevel - prev.SpeedLevel))
+		for j, p := range s.Players {
+			put(int64(p.Gold - prev.Players[j].Gold))
+			put(int64(p.Tile - prev.Players[j].Tile))
+		}
+		prev, prevStage = s, i
+	}
+	t.Stages = strings.Join(stages, ",")
+	t.Data = data
+	return &t
+}
+
+func (t *TimelineData) decode() ([]TimelineSample, error) {
+	if len(t.Data) == 0 || t.Data[0] != timelineVersion {
+		return nil, fmt.Errorf("unknown timeline encoding")
+	}
+	stages := strings.Split(t.Stages, ",")
+	data := t.Data[1:]
+	var err error
+	get := func() int64 {
+		v, n := binary.Varint(data)
+		if n <= 0 {
+			err = fmt.Errorf("timeline data is truncated")
+			return 0
+		}
+		data = data[n:]
+		return v
+	}
+	samples := make([]TimelineSample, t.Count)
+	var ms, energy int64
+	stage := 0
+	prev := TimelineSample{Players: make([]PlayerSample, t.Players)}
+	for i := range samples {
+		s := TimelineSample{}
+		ms += get()
+		s.T = float64(ms) / 1000
+		s.Gold = prev.Gold + int(get())
+		energy += get()
+		s.Energy = float64(energy) / 10
+		stage += int(get())
+		if stage >= 0 && stage < len(stages) {
+			s.Stage = stages[stage]
+		}
+		s.SpeedLevel = prev.SpeedLevel + int(get())
+		s.Players = make([]PlayerSample, t.Players)
+		for j := range s.Players {
+			s.Players[j].Gold = prev.Players[j].Gold + int(get())
+			s.Players[j].Tile = prev.Players[j].Tile + int(get())
+		}
+		if err != nil {
+			return nil, err
+		}
+		samples[i] = s
+		prev = s
+	}
+	return samples, nil
+}
+
+// downsample keeps count samples evenly spread over samples, the first and
+// the last one included
+func downsample(samples []TimelineSample, count int) []TimelineSample {
+	if count <= 0 || len(samples) <= count {
+		return samples
+	}
+	if count == 1 {
+		return samples[len(samples)-1:]
+	}
+	ret := make([]TimelineSample, count)
+	for i := range ret {
+		ret[i] = samples[i*(len(samples)-1)/(count-1)]
+	}
+	return ret
+}
+
+// GetTimeline serves the samples of match mid, at most points of them
+func (s *Srv) GetTimeline(c echo.Context) error {
+	mid, _ := strconv.Atoi(c.QueryParam("mid"))
+	points := defaultTimelinePoints
+	if p := c.QueryParam("points"); p != "" {
+		points, _ = strconv.Atoi(p)
+	}
+	md, t := s.db.getTimeline(uint(mid))
+	d := make(map[string]interface{})
+	if md == nil || t == nil {
+		d["code"] = 1
+		d["error"] = "比赛不存在或没有记录过程"
+		return c.JSON(http.StatusOK, d)
+	}
+	samples, err := t.decode()
+	if err != nil {
+		d["code"] = 1
+		d["error"] = err.Error()
+		return c.JSON(http.StatusOK, d)
+	}
+	cids := make([]string, len(md.Member))
+	for i, p := range md.Member {
+		cids[i] = p.ControllerID
+	}
+	d["code"] = 0
+	d["matchID"] = md.ID
+	d["interval"] = t.Interval
+	d["cids"] = cids
+	d["samples"] = downsample(samples, points)
+	return c.JSON(http.StatusOK, d)
+}
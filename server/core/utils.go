@@ -32,3 +32,10 @@ func MaxInt(x, y int) int {
 	}
 	return y
 }
+
+func AbsInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
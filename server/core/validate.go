@@ -0,0 +1,415 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError describes a single problem of a config value, Field is the
+// path of the value in the config file, e.g. walls[2] or lasers[0].large
+type ValidationError struct {
+	Field string
+	Msg   string
+}
+
+func (e ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Msg
+	}
+	return e.Field + ": " + e.Msg
+}
+
+// ValidationErrors collects every problem found in one validation pass
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	li := make([]string, len(errs))
+	for i, e := range errs {
+		li[i] = e.Error()
+	}
+	return strings.Join(li, "\n")
+}
+
+func (errs *ValidationErrors) add(field string, format string, args ...interface{}) {
+	*errs = append(*errs, ValidationError{field, fmt.Sprintf(format, args...)})
+}
+
+// merge appends the problems of err, prefixing their fields with prefix.
+// err that is not a ValidationErrors is added as is.
+func (errs *ValidationErrors) merge(prefix string, err error) {
+	if err == nil {
+		return
+	}
+	if li, ok := err.(ValidationErrors); ok {
+		for _, e := range li {
+			*errs = append(*errs, ValidationError{prefix + e.Field, e.Msg})
+		}
+		return
+	}
+	*errs = append(*errs, ValidationError{strings.TrimSuffix(strings.TrimSuffix(prefix, "."), ":"), err.Error()})
+}
+
+// teamSizeTable checks a table with one entry per team size, shorter tables
+// are fine as larger teams use the last entry
+func (errs *ValidationErrors) teamSizeTable(field string, n int, maxTeamSize int) {
+	if n == 0 {
+		errs.add(field, "is empty")
+	} else if maxTeamSize > 0 && n > maxTeamSize {
+		errs.add(field, "has %v entries but maxTeamSize is %v", n, maxTeamSize)
+	}
+}
+
+// laserStrategies checks the strategy names and the modes they are set for
+func (errs *ValidationErrors) laserStrategies(strategy string, strategies map[string]string) {
+	if _, ok := chaseStrategies[strategy]; strategy != "" && !ok {
+		errs.add("laserStrategy", "unknown strategy %v", strategy)
+	}
+	for mode, name := range strategies {
+		if GetGameMode(mode) == nil {
+			errs.add("laserStrategies."+mode, "unknown mode %v", mode)
+		}
+		if _, ok := chaseStrategies[name]; !ok {
+			errs.add("laserStrategies."+mode, "unknown strategy %v", name)
+		}
+	}
+}
+
+func (errs ValidationErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Validate checks the values read from cfg.toml
+func (m *MatchOptions) Validate() error {
+	var errs ValidationErrors
+	positive := func(field string, v float64) {
+		if v <= 0 {
+			errs.add(field, "must be greater than 0, got %v", v)
+		}
+	}
+	if m.ArenaWidth <= 0 {
+		errs.add("arenaWidth", "must be greater than 0, got %v", m.ArenaWidth)
+	}
+	if m.ArenaHeight <= 0 {
+		errs.add("arenaHeight", "must be greater than 0, got %v", m.ArenaHeight)
+	}
+	onGrid := func(x int, y int) bool {
+		return x >= 0 && x < m.ArenaWidth && y >= 0 && y < m.ArenaHeight
+	}
+	if !onGrid(m.ArenaEntrance.X, m.ArenaEntrance.Y) {
+		errs.add("arenaEntrance", "tile (%v, %v) is outside the %vx%v arena", m.ArenaEntrance.X, m.ArenaEntrance.Y, m.ArenaWidth, m.ArenaHeight)
+	}
+	if !onGrid(m.ArenaExit.X, m.ArenaExit.Y) {
+		errs.add("arenaExit", "tile (%v, %v) is outside the %vx%v arena", m.ArenaExit.X, m.ArenaExit.Y, m.ArenaWidth, m.ArenaHeight)
+	}
+	for i, wall := range m.Walls {
+		field := fmt.Sprintf("walls[%d]", i)
+		if len(wall) != 4 {
+			errs.add(field, "must be [x1, y1, x2, y2], got %v", wall)
+			continue
+		}
+		if !onGrid(wall[0], wall[1]) || !onGrid(wall[2], wall[3]) {
+			errs.add(field, "%v is outside the %vx%v arena", wall, m.ArenaWidth, m.ArenaHeight)
+		} else if AbsInt(wall[0]-wall[2])+AbsInt(wall[1]-wall[3]) != 1 {
+			errs.add(field, "%v does not separate two adjacent tiles", wall)
+		}
+	}
+
+	if !(m.T1 > 0 && m.T1 < m.T2 && m.T2 < m.T3) {
+		errs.add("t1", "button times must satisfy 0 < t1 < t2 < t3, got %v, %v, %v", m.T1, m.T2, m.T3)
+	}
+	positive("tRampage", m.TRampage)
+	positive("maxEnergy", m.MaxEnergy)
+	positive("mode1TotalTime", m.Mode1TotalTime)
+	positive("energySpeedup", m.EnergySpeedup)
+	positive("laserSpeed", m.LaserSpeed)
+	positive("mode2GoldDropInterval", m.Mode2GoldDropInterval)
+	positive("practiceTime", m.PracticeTime)
+	if m.PracticeButtons < 1 && len(m.PracticePath) == 0 {
+		errs.add("practiceButtons", "must be greater than 0 without practicePath, got %v", m.PracticeButtons)
+	}
+	if len(m.PracticePath) > 0 {
+		buttons := make(map[string]bool)
+		for _, id := range m.MainArduino {
+			buttons[id] = true
+		}
+		for i, id := range m.PracticePath {
+			if !buttons[id] {
+				errs.add(fmt.Sprintf("practicePath[%d]", i), "unknown button %v", id)
+			}
+		}
+	}
+	if m.TimelineInterval < 0 {
+		errs.add("timelineInterval", "must not be negative, got %v", m.TimelineInterval)
+	}
+	if m.EnergySpeedup > 0 {
+		level := float64(int(m.MaxEnergy / m.EnergySpeedup))
+		for i, v := range m.LaserSpeedup {
+			if m.LaserSpeed-level*v <= 0 {
+				errs.add(fmt.Sprintf("laserSpeedup[%d]", i), "laser interval drops to %v at max energy", m.LaserSpeed-level*v)
+			}
+		}
+	}
+	for i, v := range m.FirstComboInterval {
+		positive(fmt.Sprintf("firstComboInterval[%d]", i), v)
+	}
+	for i, v := range m.ComboInterval {
+		positive(fmt.Sprintf("comboInterval[%d]", i), v)
+	}
+	for i, v := range m.RampageTime {
+		positive(fmt.Sprintf("rampageTime[%d]", i), v)
+	}
+	if m.CatchMode != 0 && m.CatchMode != 1 {
+		errs.add("catchMode", "must be 0 or 1, got %v", m.CatchMode)
+	}
+	if m.CatchMode == 0 && m.CatchLaserNum <= 0 {
+		errs.add("catchLaserNum", "must be greater than 0 when catchMode is 0, got %v", m.CatchLaserNum)
+	}
+	if m.CatchMinConfidence < 0 || m.CatchMinConfidence > 1 {
+		errs.add("catchMinConfidence", "must be between 0 and 1, got %v", m.CatchMinConfidence)
+	}
+	positive("positionTileSpeed", m.PositionTileSpeed)
+	positive("positionTimeout", m.PositionTimeout)
+	if m.PositionJumpConfirm < 1 {
+		errs.add("positionJumpConfirm", "must be greater than 0, got %v", m.PositionJumpConfirm)
+	}
+	if m.DropoutGrace < 0 {
+		errs.add("dropoutGrace", "must not be negative, got %v", m.DropoutGrace)
+	}
+	switch m.DropoutPolicy {
+	case DropoutPolicyPause, DropoutPolicyPatrol, DropoutPolicyContinue:
+	default:
+		errs.add("dropoutPolicy", "unknown policy %q", m.DropoutPolicy)
+	}
+
+	ids := make(map[string]string)
+	unique := func(field string, id string) {
+		if id == "" {
+			errs.add(field, "is empty")
+		} else if other, ok := ids[id]; ok {
+			errs.add(field, "%v is duplicated with %v", id, other)
+		} else {
+			ids[id] = field
+		}
+	}
+	for i, id := range m.MainArduino {
+		field := fmt.Sprintf("mainArduino[%d]", i)
+		unique(field, id)
+		info, err := parseArduinoID(id)
+		if err != nil {
+			errs.add(field, "%v", err.Error())
+		} else if !onGrid(info.X-1, info.Y-1) {
+			errs.add(field, "%v is outside the %vx%v arena", id, m.ArenaWidth, m.ArenaHeight)
+		}
+	}
+	for i, id := range m.SubArduino {
+		unique(fmt.Sprintf("subArduino[%d]", i), id)
+	}
+	for i, id := range m.MusicArduino {
+		unique(fmt.Sprintf("musicArduino[%d]", i), id)
+	}
+	for i, id := range m.DoorArduino {
+		unique(fmt.Sprintf("doorArduino[%d]", i), id)
+	}
+	for i, n := range m.InitButtonNum {
+		if n <= 0 || n > len(m.MainArduino) {
+			errs.add(fmt.Sprintf("initButtonNum[%d]", i), "must be between 1 and %v buttons, got %v", len(m.MainArduino), n)
+		}
+	}
+	for i, t := range m.LocationTransfers {
+		if t.To < 1 || t.To > m.ArenaWidth*m.ArenaHeight {
+			errs.add(fmt.Sprintf("locationTransfers[%d].to", i), "location %v is outside the arena", t.To)
+		}
+	}
+	if m.MaxTeamSize < 1 {
+		errs.add("maxTeamSize", "must be greater than 0, got %v", m.MaxTeamSize)
+	}
+	tables := []struct {
+		field string
+		n     int
+	}{
+		{"mode2InitGold", len(m.Mode2InitGold)},
+		{"mode2GoldDropRate", len(m.Mode2GoldDropRate)},
+		{"initButtonNum", len(m.InitButtonNum)},
+		{"firstComboInterval", len(m.FirstComboInterval)},
+		{"comboInterval", len(m.ComboInterval)},
+		{"laserSpeedup", len(m.LaserSpeedup)},
+		{"mode1TouchPunish", len(m.Mode1TouchPunish)},
+		{"mode2TouchPunish", len(m.Mode2TouchPunish)},
+		{"goldRank", len(m.GoldRank)},
+		{"goldTeamRank", len(m.GoldTeamRank)},
+		{"survivalRank", len(m.SurvivalRank)},
+		{"survivalTeamRank", len(m.SurvivalTeamRank)},
+	}
+	if len(m.EnergyBonus) != buttonLevelNum {
+		errs.add("energyBonus", "must have %v rows, one per button level, got %v", buttonLevelNum, len(m.EnergyBonus))
+	}
+	for i, row := range m.EnergyBonus {
+		errs.teamSizeTable(fmt.Sprintf("energyBonus[%d]", i), len(row), m.MaxTeamSize)
+	}
+	for _, t := range tables {
+		errs.teamSizeTable(t.field, t.n, m.MaxTeamSize)
+	}
+	ranks := []struct {
+		name string
+		data [][]int
+	}{
+		{"goldRank", m.GoldRank},
+		{"goldTeamRank", m.GoldTeamRank},
+		{"survivalRank", m.SurvivalRank},
+		{"survivalTeamRank", m.SurvivalTeamRank},
+	}
+	for _, rank := range ranks {
+		for i, row := range rank.data {
+			if len(row) != 4 {
+				errs.add(fmt.Sprintf("%v[%d]", rank.name, i), "must have 4 thresholds S, A, B, C, got %v", row)
+				continue
+			}
+			for j := 1; j < len(row); j++ {
+				if row[j] > row[j-1] {
+					errs.add(fmt.Sprintf("%v[%d]", rank.name, i), "thresholds must be in descending order S, A, B, C, got %v", row)
+					break
+				}
+			}
+		}
+	}
+	errs.laserStrategies(m.LaserStrategy, m.LaserStrategies)
+	errs.merge("powerUps.", m.PowerUps.Validate())
+	errs.merge("scoring.", m.Scoring.Validate(m))
+	for name, p := range m.Profiles {
+		field := "profiles." + name
+		if name == defaultProfileName {
+			errs.add(field, "%v is reserved for matches without a profile", name)
+			continue
+		}
+		errs.merge(field+".", p.Validate(m))
+	}
+	return errs.err()
+}
+
+// Validate checks the values a profile overrides, base is the normal game
+func (p *GameProfile) Validate(base *MatchOptions) error {
+	var errs ValidationErrors
+	if p.LaserSpeed != nil {
+		if *p.LaserSpeed <= 0 {
+			errs.add("laserSpeed", "must be greater than 0, got %v", *p.LaserSpeed)
+		} else if base.EnergySpeedup > 0 {
+			level := float64(int(base.MaxEnergy / base.EnergySpeedup))
+			for i, v := range base.LaserSpeedup {
+				if *p.LaserSpeed-level*v <= 0 {
+					errs.add("laserSpeed", "laser interval drops to %v at max energy for %v players", *p.LaserSpeed-level*v, i+1)
+				}
+			}
+		}
+	}
+	if p.LaserAppearTime != nil && *p.LaserAppearTime < 0 {
+		errs.add("laserAppearTime", "must not be negative, got %v", *p.LaserAppearTime)
+	}
+	if p.Mode1TotalTime != nil && *p.Mode1TotalTime <= 0 {
+		errs.add("mode1TotalTime", "must be greater than 0, got %v", *p.Mode1TotalTime)
+	}
+	if p.Mode1TouchPunish != nil {
+		errs.teamSizeTable("mode1TouchPunish", len(*p.Mode1TouchPunish), base.MaxTeamSize)
+		for i, v := range *p.Mode1TouchPunish {
+			if v < 0 {
+				errs.add(fmt.Sprintf("mode1TouchPunish[%d]", i), "must not be negative, got %v", v)
+			}
+		}
+	}
+	if p.Mode2TouchPunish != nil {
+		errs.teamSizeTable("mode2TouchPunish", len(*p.Mode2TouchPunish), base.MaxTeamSize)
+		for i, v := range *p.Mode2TouchPunish {
+			if v < 0 {
+				errs.add(fmt.Sprintf("mode2TouchPunish[%d]", i), "must not be negative, got %v", v)
+			}
+		}
+	}
+	if p.RampageTime != nil {
+		for i, v := range p.RampageTime {
+			if v <= 0 {
+				errs.add(fmt.Sprintf("rampageTime[%d]", i), "must be greater than 0, got %v", v)
+			}
+		}
+	}
+	strategy, strategies := "", map[string]string(nil)
+	if p.LaserStrategy != nil {
+		strategy = *p.LaserStrategy
+	}
+	if p.LaserStrategies != nil {
+		strategies = *p.LaserStrategies
+	}
+	errs.laserStrategies(strategy, strategies)
+	return errs.err()
+}
+
+func (o *PowerUpOptions) Validate() error {
+	var errs ValidationErrors
+	if o.Chance < 0 || o.Chance > 1 {
+		errs.add("chance", "must be between 0 and 1, got %v", o.Chance)
+	}
+	if o.Chance > 0 && o.totalWeight() <= 0 {
+		errs.add("chance", "is %v but no power-up has a weight", o.Chance)
+	}
+	for _, kind := range o.kinds() {
+		p := o.get(kind)
+		if p.Weight < 0 {
+			errs.add(kind+".weight", "must not be negative, got %v", p.Weight)
+		}
+		if p.Weight > 0 && kind != PowerUpReveal && p.Duration <= 0 {
+			errs.add(kind+".duration", "must be greater than 0, got %v", p.Duration)
+		}
+	}
+	return errs.err()
+}
+
+// Validate checks the values read from warmup.toml
+func (w *WarmupInfo) Validate() error {
+	var errs ValidationErrors
+	if w.WarmupTime < 0 {
+		errs.add("warmupTime", "must not be negative, got %v", w.WarmupTime)
+	}
+	if w.WarmupButtonInterval <= 0 {
+		errs.add("warmupButtonInterval", "must be greater than 0, got %v", w.WarmupButtonInterval)
+	}
+	last := 0
+	for i, laser := range w.Lasers {
+		field := fmt.Sprintf("lasers[%d]", i)
+		if laser.Time < last || laser.Time > w.WarmupTime {
+			errs.add(field+".time", "must be ascending and within warmupTime %v, got %v", w.WarmupTime, laser.Time)
+		} else {
+			last = laser.Time
+		}
+		if len(laser.Large) != warmupLargeLaserNum {
+			errs.add(field+".large", "must have %v values, got %v", warmupLargeLaserNum, len(laser.Large))
+		}
+		if len(laser.Small) != warmupSmallLaserNum {
+			errs.add(field+".small", "must have %v values, got %v", warmupSmallLaserNum, len(laser.Small))
+		}
+	}
+	return errs.err()
+}
+
+// Validate checks the values read from survey.toml
+func (s *Survey) Validate() error {
+	var errs ValidationErrors
+	if len(s.Questions) == 0 {
+		errs.add("questions", "is empty")
+	}
+	for i, q := range s.Questions {
+		field := fmt.Sprintf("questions[%d]", i)
+		if strings.TrimSpace(q.Q) == "" {
+			errs.add(field+".q", "is empty")
+		}
+		if len(q.Options) < 2 {
+			errs.add(field+".options", "must have at least 2 options, got %v", len(q.Options))
+		}
+		for j, o := range q.Options {
+			if strings.TrimSpace(o) == "" {
+				errs.add(fmt.Sprintf("%v.options[%d]", field, j), "is empty")
+			}
+		}
+	}
+	return errs.err()
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+const versusSideNum = 2
+
+// Side is one of the two teams of a versus match, Index is 1 or 2
+type Side struct {
+	Index        int     `json:"index"`
+	Size         int     `json:"size"`
+	Gold         int     `json:"gold"`
+	Energy       float64 `json:"energy"`
+	RampageTime  float64 `json:"rampageTime"`
+	RampageCount int     `json:"rampageCount"`
+
+	lasers []LaserInterface
+}
+
+// memberChecker is implemented by modes that only work with some team sizes
+type memberChecker interface {
+	CheckMembers(n int) error
+}
+
+// 对战模式, the members are split into two sides racing for gold. A side
+// whose energy is full and whose members stand together sends lasers after
+// every member of the other side for rampageTime.
+type versusMode struct{}
+
+func init() {
+	RegisterGameMode(versusMode{})
+}
+
+func (versusMode) Name() string {
+	return "v"
+}
+
+func (versusMode) Title() string {
+	return "对战"
+}
+
+func (versusMode) OptionIndex() int {
+	return 0
+}
+
+func (versusMode) Effects() StageEffects {
+	return bountyMode{}.Effects()
+}
+
+func (versusMode) CheckMembers(n int) error {
+	if n < versusSideNum {
+		return fmt.Errorf("对战模式至少需要%v人", versusSideNum)
+	}
+	return nil
+}
+
+// Init puts the first half of the members in side 1 and the rest in side 2
+func (versusMode) Init(m *Match) {
+	m.TotalTime = m.opt.Mode1TotalTime
+	m.Sides = make([]*Side, versusSideNum)
+	for i := range m.Sides {
+		m.Sides[i] = &Side{Index: i + 1}
+	}
+	half := (len(m.Member) + 1) / 2
+	for i, player := range m.Member {
+		if i < half {
+			player.Side = 1
+		} else {
+			player.Side = 2
+		}
+		m.Sides[player.Side-1].Size += 1
+	}
+}
+
+func (versusMode) Start(m *Match) {
+}
+
+func (mode versusMode) Tick(m *Match, sec float64) {
+	m.TotalTime = math.Max(m.TotalTime-sec, 0)
+	energy := 0.0
+	for _, side := range m.Sides {
+		if side.RampageTime > 0 {
+			side.RampageTime = math.Max(side.RampageTime-sec, 0)
+			if side.RampageTime <= 0 {
+				mode.stopRampage(m, side)
+			}
+		} else if m.scoring.canRampage(side.Energy, m.opt.MaxEnergy, m.together(m.sideMembers(side.Index))) {
+			mode.startRampage(m, side)
+		}
+		energy = math.Max(energy, side.Energy)
+	}
+	// stage leds and laser speed follow the side closest to rampage
+	m.Energy = energy
+}
+
+func (versusMode) startRampage(m *Match, side *Side) {
+	side.Energy = 0
+	side.RampageCount += 1
+	side.RampageTime = m.opt.RampageTime[m.modeIndex()]
+	m.RampageCount += 1
+	m.feed(FeedRampageStart, map[string]interface{}{"side": side.Index, "duration": side.RampageTime})
+	for _, player := range m.Member {
+		if player.Side != side.Index {
+			l := m.newLaser(m.freeTile(), player)
+			side.lasers = append(side.lasers, l)
+			m.Lasers = append(m.Lasers, l)
+		}
+	}
+}
+
+func (versusMode) stopRampage(m *Match, side *Side) {
+	for _, l := range side.lasers {
+		m.removeLaser(l)
+	}
+	side.lasers = nil
+	m.feed(FeedRampageEnd, map[string]interface{}{"side": side.Index})
+}
+
+func (versusMode) ConsumeButton(m *Match, p *Player, level int) {
+	side := m.Sides[p.Side-1]
+	m.scoreButton(p, level, side.RampageTime > 0, &side.Gold, &side.Energy)
+	m.Gold = m.sidesGold()
+}
+
+func (versusMode) TouchPunish(m *Match, p *Player) {
+	side := m.Sides[p.Side-1]
+	punish := m.scoring.touchGold(m.modeIndex(), side.Size)
+	side.Gold -= punish
+	p.LostGold += punish
+	m.Gold = m.sidesGold()
+}
+
+// NextStage never enters the shared rampage stage, sides rampage on their own
+func (versusMode) NextStage(m *Match, s string) string {
+	if s == "ongoing-rampage" {
+		s = "ongoing-full"
+	}
+	if m.TotalTime < m.opt.Mode1CountDown {
+		s = "ongoing-countdown"
+	}
+	if m.TotalTime <= 0 {
+		s = "after"
+	}
+	return s
+}
+
+// TeamGrade is the grade of the winning side
+func (versusMode) TeamGrade(m *Match) string {
+	winner := m.Sides[0]
+	for _, side := range m.Sides {
+		if side.Gold > winner.Gold {
+			winner = side
+		}
+	}
+	return m.opt.calcGrade(winner.Gold, winner.Size, m.opt.GoldTeamRank)
+}
+
+func (versusMode) PersonGrade(m *Match, p *Player) string {
+	return m.opt.calcGrade(p.Gold-p.LostGold, m.Sides[p.Side-1].Size, m.opt.GoldRank)
+}
+
+func (m *Match) sideMembers(index int) []*Player {
+	li := make([]*Player, 0)
+	for _, player := range m.Member {
+		if player.Side == index {
+			li = append(li, player)
+		}
+	}
+	return li
+}
+
+func (m *Match) sidesGold() int {
+	gold := 0
+	for _, side := range m.Sides {
+		gold += side.Gold
+	}
+	return gold
+}
+
+// winnerSide is the index of the side with more gold, 0 for a draw
+func (m *Match) winnerSide() int {
+	if len(m.Sides) != versusSideNum || m.Sides[0].Gold == m.Sides[1].Gold {
+		return 0
+	}
+	if m.Sides[0].Gold > m.Sides[1].Gold {
+		return 1
+	}
+	return 2
+}
@@ -20,16 +20,6 @@ import (
 	_ "net/http/pprof"
 )
 
-const (
-	host        = "10.0.0.11"
-	httpAddr    = host + ":3000"
-	tcpAddr     = host + ":4000"
-	udpAddr     = host + ":5000"
-	dbPath      = "./challenger.db"
-	isSimulator = false
-	testRank    = true
-)
-
 func redirectStderr(f *os.File) {
 	err := syscall.Dup2(int(f.Fd()), int(os.Stderr.Fd()))
 	if err != nil {
@@ -52,7 +42,88 @@ func loadRankTestData() map[string]interface{} {
 	return ret
 }
 
+func runCommand(cfg *ServerConfig, args []string) {
+	switch args[0] {
+	case "validate-config":
+		arenas, err := cfg.ArenaConfigs()
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		ok := true
+		for _, a := range arenas {
+			if err := core.ValidateConfig(a.ConfigPaths()); err != nil {
+				if a.Name != "" {
+					fmt.Printf("arena %v:\n", a.Name)
+				}
+				fmt.Println(err.Error())
+				ok = false
+			}
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		fmt.Println("config ok")
+	case "replay":
+		if len(args) < 2 {
+			fmt.Println("usage: challenger replay <match-id> [arena]")
+			os.Exit(2)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Println("invalid match id:", args[1])
+			os.Exit(2)
+		}
+		arena, err := findArena(cfg, args[2:])
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		srvConfig, err := core.LoadSrvConfig(arena.ConfigPaths(), cfg.IsSimulator)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		log.SetOutput(ioutil.Discard)
+		if err := core.ReplayMatch(srvConfig, core.RecordPath(cfg.RecordDir, uint(id)), os.Stdout); err != nil {
+			fmt.Println("replay error:", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Println("unknown command:", args[0])
+		os.Exit(2)
+	}
+}
+
+// findArena is the arena named by the first of args, the first arena
+// without args
+func findArena(cfg *ServerConfig, args []string) (*ArenaConfig, error) {
+	arenas, err := cfg.ArenaConfigs()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return &arenas[0], nil
+	}
+	for i := range arenas {
+		if arenas[i].Name == args[0] {
+			return &arenas[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown arena:%v", args[0])
+}
+
 func main() {
+	cfg, args, err := LoadServerConfig(os.Args[1:])
+	if err != nil {
+		fmt.Println("load server config error:", err)
+		os.Exit(1)
+	}
+	if len(args) > 0 {
+		runCommand(cfg, args)
+		return
+	}
+
 	// setup log system
 	log.Println("start server")
 	logfileName := "log/" + time.Now().Local().Format("2006-01-02-15-04-05") + ".log"
@@ -72,7 +143,10 @@ func main() {
 	}
 	log.SetOutput(io.MultiWriter(f, os.Stdout))
 	log.Println("setup log system done")
-	go http.ListenAndServe(":8081", http.DefaultServeMux)
+	log.Printf("server config:%+v\n", *cfg)
+	if cfg.PprofAddr != "" {
+		go http.ListenAndServe(cfg.PprofAddr, http.DefaultServeMux)
+	}
 
 	defer func() {
 		if err := recover(); err != nil { //catch
@@ -83,18 +157,33 @@ func main() {
 
 	var rankTestData map[string]interface{}
 
-	if testRank {
+	if cfg.TestRank {
 		rankTestData = loadRankTestData()
 	}
 
-	core.GetOptions()
-	core.GetSurvey()
-	core.GetLaserPair()
+	arenas, err := cfg.ArenaConfigs()
+	if err != nil {
+		log.Printf("load config error:%v\n", err.Error())
+		os.Exit(1)
+	}
+	hub := core.NewHub()
+	for _, a := range arenas {
+		srvConfig, err := core.LoadSrvConfig(a.ConfigPaths(), cfg.IsSimulator)
+		if err != nil {
+			log.Printf("load config of arena %q error:\n%v\n", a.Name, err.Error())
+			os.Exit(1)
+		}
+		srvConfig.RecordDir = cfg.RecordDir
+		srvConfig.Arena = a.Name
+		if _, err := hub.AddArena(srvConfig, cfg.ArenaTcpAddr(a), cfg.ArenaUdpAddr(a)); err != nil {
+			log.Printf("add arena error:%v\n", err.Error())
+			os.Exit(1)
+		}
+	}
 
 	log.Println("reading cfg done")
 
-	srv := core.NewSrv(isSimulator)
-	go srv.Run(tcpAddr, udpAddr, dbPath)
+	go hub.Run(cfg.DBPath)
 
 	// setup echo
 	ec := echo.New()
@@ -102,41 +191,26 @@ func main() {
 	ec.Static("/api/asset/", "api_public")
 	ec.Use(mw.Logger())
 	ec.Get("/ws", st.WrapHandler(websocket.Handler(func(ws *websocket.Conn) {
-		srv.ListenWebSocket(ws)
+		hub.ListenWebSocket(ws)
 	})))
-	ec.Post("/api/addteam", func(c echo.Context) error {
-		return srv.AddTeam(c)
-	})
-	ec.Post("/api/resetqueue", func(c echo.Context) error {
-		return srv.ResetQueue(c)
-	})
-	ec.Get("/api/history", func(c echo.Context) error {
-		return srv.GetHistory(c)
-	})
-	ec.Post("/api/start_answer", func(c echo.Context) error {
-		return srv.MatchStartAnswer(c)
-	})
-	ec.Post("/api/stop_answer", func(c echo.Context) error {
-		return srv.MatchStopAnswer(c)
-	})
-	ec.Get("/api/survey", func(c echo.Context) error {
-		return srv.GetSurvey(c)
-	})
-	ec.Post("/api/answer", func(c echo.Context) error {
-		return srv.UpdateQuestionInfo(c)
-	})
-	ec.Get("/api/answering", func(c echo.Context) error {
-		return srv.GetAnsweringMatchData(c)
-	})
-	ec.Post("/api/update_player", func(c echo.Context) error {
-		return srv.UpdatePlayerData(c)
-	})
-	ec.Post("/api/update_match", func(c echo.Context) error {
-		return srv.UpdateMatchData(c)
-	})
-	ec.Get("/api/sender_list", func(c echo.Context) error {
-		return srv.GetMainArduinoList(c)
+	ec.Get("/api/arenas", func(c echo.Context) error {
+		return hub.GetArenas(c)
 	})
+	ec.Post("/api/addteam", hub.ArenaHandler((*core.Srv).AddTeam))
+	ec.Get("/api/modes", hub.ArenaHandler((*core.Srv).GetModes))
+	ec.Get("/api/profiles", hub.ArenaHandler((*core.Srv).GetProfiles))
+	ec.Post("/api/resetqueue", hub.ArenaHandler((*core.Srv).ResetQueue))
+	ec.Get("/api/history", hub.ArenaHandler((*core.Srv).GetHistory))
+	ec.Get("/api/timeline", hub.ArenaHandler((*core.Srv).GetTimeline))
+	ec.Post("/api/start_answer", hub.ArenaHandler((*core.Srv).MatchStartAnswer))
+	ec.Post("/api/stop_answer", hub.ArenaHandler((*core.Srv).MatchStopAnswer))
+	ec.Get("/api/survey", hub.ArenaHandler((*core.Srv).GetSurvey))
+	ec.Post("/api/answer", hub.ArenaHandler((*core.Srv).UpdateQuestionInfo))
+	ec.Get("/api/answering", hub.ArenaHandler((*core.Srv).GetAnsweringMatchData))
+	ec.Post("/api/update_player", hub.ArenaHandler((*core.Srv).UpdatePlayerData))
+	ec.Post("/api/update_match", hub.ArenaHandler((*core.Srv).UpdateMatchData))
+	ec.Get("/api/sender_list", hub.ArenaHandler((*core.Srv).GetMainArduinoList))
+	ec.Post("/api/reload_config", hub.ArenaHandler((*core.Srv).ReloadConfigHandler))
 	ec.Get("/api/allhistory", func(c echo.Context) error {
 		if rankTestData == nil {
 			return c.JSON(http.StatusOK, nil)
@@ -160,6 +234,6 @@ func main() {
 		data["error"] = ""
 		return c.JSON(http.StatusOK, data)
 	})
-	log.Println("listen http:", httpAddr)
-	ec.Run(st.New(httpAddr))
+	log.Println("listen http:", cfg.HttpAddr())
+	ec.Run(st.New(cfg.HttpAddr()))
 }
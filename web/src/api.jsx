@@ -4,7 +4,7 @@ import { observable, computed } from 'mobx'
 import { observer } from 'mobx-react'
 import CSSModules from 'react-css-modules'
 import styles from '~/styles/api.css'
-import { wsAddressWithPath } from '~/js/util.jsx'
+import { wsAddressWithPath, arena } from '~/js/util.jsx'
 
 class Api {
 	@ observable state
@@ -36,6 +36,7 @@ class Api {
 				cmd: 'init',
 				ID: 'api_test',
 				TYPE: '3',
+				ARENA: arena(),
 			}
 			sock.send(JSON.stringify(data))
 		}
@@ -36,13 +36,22 @@ const App = CSSModules(observer(React.createClass({
       cursor: 'pointer',
       zIndex: '1',
     }
+    let pauseStyle = Object.assign({}, resetStyle, {right: '80px'})
+    let pause = null
+    if (game.match != null) {
+      pause = <div onClick={this.togglePause} style={pauseStyle}>{game.match.stage == 'paused' ? '继续游戏' : '暂停游戏'}</div>
+    }
     return (
       <div id='app' styleName='base-div'>
         <div onClick={this.reset} style={resetStyle}>重置游戏</div>
+        {pause}
         {element}
       </div>
     )
   },
+  togglePause: function(e) {
+    this.props.game.togglePause()
+  },
   reset: function(e) {
     this.props.game.resetMatch()
   }
@@ -4,10 +4,13 @@ import { observable, computed } from 'mobx'
 import { observer } from 'mobx-react'
 import CSSModules from 'react-css-modules'
 import styles from '~/styles/front.css'
+import { withArena } from '~/js/util.jsx'
 
 
 class Front {
 	@observable number
+	@observable profiles = []
+	@observable modes = [{name: 'g', title: '赏金'}, {name: 's', title: '生存'}]
 }
 
 const FrontView = CSSModules(observer(React.createClass({
@@ -16,12 +19,24 @@ const FrontView = CSSModules(observer(React.createClass({
 		return (
 			<div styleName='root'>
 				<div styleName='title'>暴走的金币</div>
-				<input type='radio' name='num' ref='p1' value='p1' defaultChecked /><span>1人</span>
-				<input type='radio' name='num' ref='p2' value='p2' /><span>2人</span>
-				<input type='radio' name='num' ref='p3' value='p3' /><span>3人</span>
-				<input type='radio' name='num' ref='p4' value='p4' /><span>4人</span><br/><br/><br/>
-				<input type='radio' name='mode' ref='gold' value='gold' defaultChecked /><span>赏金</span>
-				<input type='radio' name='mode' ref='survival' value='survival' /><span>生存</span><br/>
+				{
+					[1, 2, 3, 4, 5, 6, 7, 8].map(n => (
+						<span key={n}><input type='radio' name='num' ref={'p' + n} value={'p' + n} defaultChecked={n == 1} /><span>{n}人</span></span>
+					))
+				}
+				<br/><br/><br/>
+				{
+					this.props.front.modes.map((mode, i) => (
+						<span key={mode.name}><input type='radio' name='mode' ref={'mode-' + mode.name} value={mode.name} defaultChecked={i == 0} /><span>{mode.title}</span></span>
+					))
+				}
+				<br/>
+				<select ref='profile' defaultValue=''>
+					<option value=''>标准</option>
+					{
+						this.props.front.profiles.map(name => <option key={name} value={name}>{name}</option>)
+					}
+				</select><br/>
 				<button styleName='add' onClick={this.addTeam}>取号</button>
 				<label>当前号码</label><br/>
 				{
@@ -31,24 +46,40 @@ const FrontView = CSSModules(observer(React.createClass({
 			</div>
 		)
 	},
+	componentDidMount: function() {
+		let front = this.props.front
+		$.get(withArena('/api/modes'), function(data) {
+			if (data) {
+				front.modes = data
+			}
+		})
+		$.get(withArena('/api/profiles'), function(data) {
+			if (data) {
+				front.profiles = data
+			}
+		})
+	},
 	addTeam: function(e) {
 		let front = this.props.front
 		var c = 1
-		if (this.refs.p1.checked) {
-			c = 1
-		} else if (this.refs.p2.checked) {
-			c = 2
-		} else if (this.refs.p3.checked) {
-			c = 3
-		} else {
-			c = 4
+		for (var n = 1; n <= 8; n++) {
+			if (this.refs['p' + n].checked) {
+				c = n
+			}
+		}
+		var mode = front.modes[0].name
+		for (let m of front.modes) {
+			if (this.refs['mode-' + m.name].checked) {
+				mode = m.name
+			}
 		}
 		let param = {
 			count: c,
-			mode: this.refs.gold.checked ? 'g' : 's'
+			mode: mode,
+			profile: this.refs.profile.value
 		}
-		$.post('/api/addteam', param, function(data) {
-			if (data) {
+		$.post(withArena('/api/addteam'), param, function(data) {
+			if (data && data.id) {
 				front.number = data.id
 			}
 		})
@@ -57,7 +88,7 @@ const FrontView = CSSModules(observer(React.createClass({
 		var r = window.confirm('确定要重置吗？')
 		if (r == true) {
 			let front = this.props.front
-			$.post('/api/resetqueue', function(data) {
+			$.post(withArena('/api/resetqueue'), function(data) {
 				front.number = null
 			})
 		}
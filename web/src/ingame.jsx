@@ -11,6 +11,7 @@ class IngameData {
 	@ observable match
 	@ observable connected
 	@ observable leaving
+	@ observable result
 
 	constructor() {
 		this._reset()
@@ -18,9 +19,12 @@ class IngameData {
 
 	_reset() {
 		this.sock = null
+		this.state = null
+		this.seq = 0
 		this.match = null
 		this.connected = false
 		this.leaving = false
+		this.result = null
 	}
 
 	connect() {
@@ -36,8 +40,10 @@ class IngameData {
 				cmd: 'init',
 				ID: 'ingame',
 				TYPE: '9',
+				ARENA: util.arena(),
 			}
 			sock.send(JSON.stringify(data))
+			sock.send(JSON.stringify({cmd: 'subscribeState', rate: 20}))
 		}
 		sock.onclose = (e) => {
 			this._reset()
@@ -54,18 +60,31 @@ class IngameData {
 			case 'init':
 				this.connected = true
 				break
-			case 'updateMatch':
-				if (json.data != null && this.connected) {
-					this.match = JSON.parse(json.data)
+			case 'stateSnapshot':
+				this.state = json.data.state
+				this.seq = json.data.seq
+				this.match = JSON.parse(JSON.stringify(this.state))
+				break
+			case 'stateDelta':
+				// a lost delta can not be applied, ask for a new snapshot
+				if (this.state == null || json.data.seq != this.seq + 1) {
+					this.sock.send(JSON.stringify({cmd: 'subscribeState', rate: 20}))
+					break
 				}
+				this.seq = json.data.seq
+				util.applyStateDelta(this.state, json.data)
+				this.match = JSON.parse(JSON.stringify(this.state))
 				break
 			case 'reset':
 				this.match = null
 				this.leaving = false
+				this.result = null
 				break
 			case 'matchStop':
+				this.state = null
 				this.match = null
 				this.leaving = true
+				this.result = json.data.matchData
 				break
 		}
 	}
@@ -93,9 +112,10 @@ const PlayerInfo = CSSModules(observer(React.createClass({
 				</div>
 			)
 		} else {
+			let nameStyle = player.side > 0 ? 'sideName' + player.side : 'tableName'
 			return (
 				<div style={style}>
-					<div styleName='tableName'>{util.playerStr(player.cid)}</div>
+					<div styleName={nameStyle}>{util.playerStr(player.cid)}</div>
 					<img styleName='tableImg' src={require('./assets/energy_on.png')} />
 					<div styleName='tableEnergy'>{player.energy}</div>
 				</div>
@@ -104,6 +124,23 @@ const PlayerInfo = CSSModules(observer(React.createClass({
 	}
 })), styles)
 
+const SideResult = CSSModules(observer(React.createClass({
+	render() {
+		let sides = this.props.sides
+		return (
+			<div styleName='sideResult'>
+				{
+					sides.map(side => (
+						<div key={side.side} styleName={'sideGold' + side.side}>
+							{(side.side == 1 ? '红方 ' : '蓝方 ') + side.gold + 'G' + (side.win ? ' WIN' : '')}
+						</div>
+					))
+				}
+			</div>
+		)
+	}
+})), styles)
+
 const IngameView = CSSModules(observer(React.createClass({
 	render() {
 		let data = this.props.data
@@ -112,6 +149,9 @@ const IngameView = CSSModules(observer(React.createClass({
 				return (
 					<div styleName='root'>
 					<img src={require('./assets/ingame_post.jpg')} />
+					{
+						data.result && data.result.sides && data.result.sides.length > 0 ? <SideResult sides={data.result.sides} /> : null
+					}
 				</div>
 				)
 			}
@@ -123,6 +163,9 @@ const IngameView = CSSModules(observer(React.createClass({
 		} else {
 			var content = []
 			let sortedMember = data.match.member.sort((a, b) => {
+				if (a.side != b.side) {
+					return a.side - b.side
+				}
 				return a.cid.localeCompare(b.cid)
 			})
 			content = []
@@ -133,13 +176,13 @@ const IngameView = CSSModules(observer(React.createClass({
 					content.push(<PlayerInfo idx={i} key ={i} />)
 				}
 			}
-			if (data.match.mode == 'g') {
+			if (data.match.mode == 'g' || data.match.mode == 'v') {
 				var time = util.timeStr(data.match.totalTime, 0)
 			} else {
 				var time = util.timeStr(data.match.elasped, 0)
 			}
 			var barBg, barFront
-			if (data.match.mode == 'g') {
+			if (data.match.mode == 'g' || data.match.mode == 'v') {
 				barBg = require('./assets/g_b.png')
 				barFront = data.match.rampageTime > 0 ? require('./assets/g_r.png') : require('./assets/g_n.png')
 			} else {
@@ -162,7 +205,13 @@ const IngameView = CSSModules(observer(React.createClass({
 			return (
 				<div styleName='root'>
 					<img src={require('./assets/ibg.png')} />
-					<div styleName='goldValue'>{data.match.gold + 'G'}</div>
+					{
+						data.match.sides ?
+							<div styleName='sidesGoldValue'>
+								<span styleName='sideGold1'>{data.match.sides[0].gold}</span>:<span styleName='sideGold2'>{data.match.sides[1].gold}</span>
+							</div> :
+							<div styleName='goldValue'>{data.match.gold + 'G'}</div>
+					}
 					<div styleName='timeValue'>{time}</div>
 					<div styleName='tableBg'>
 						<img styleName='tableBgImg' src={require('./assets/itb.png')}/>
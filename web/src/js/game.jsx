@@ -1,5 +1,5 @@
 import { observable, computed } from 'mobx'
-import { wsAddressWithPath } from '~/js/util.jsx'
+import { wsAddressWithPath, arena } from '~/js/util.jsx'
 
 class Game {
   @observable match
@@ -62,7 +62,8 @@ class Game {
       let data = {
         cmd: 'init',
         ID: playerName,
-        TYPE: '2'
+        TYPE: '2',
+        ARENA: arena()
       }
       this.sock.send(JSON.stringify(data))
     }
@@ -92,6 +93,16 @@ class Game {
     }
   }
 
+  togglePause() {
+    if (this.matchID > 0 && this.match != null && this.sock) {
+      let data = {
+        cmd: this.match.stage == 'paused' ? 'resumeMatch' : 'pauseMatch',
+        matchID: this.matchID,
+      }
+      this.sock.send(JSON.stringify(data))
+    }
+  }
+
   onMessage(msg) {
     let json = JSON.parse(msg)
     console.log(msg)
@@ -27,6 +27,7 @@ const MatchView = observer(React.createClass({
         }
         <button onClick={this.startGoldMode}>开始赏金模式</button>
         <button onClick={this.startSurvivalMode}>开始生存模式</button>
+        <button onClick={this.startPracticeMode}>开始练习模式</button>
       </div>
     )
   },
@@ -36,6 +37,9 @@ const MatchView = observer(React.createClass({
   startSurvivalMode: function(e) {
     this.props.game.startMatch('s')
   },
+  startPracticeMode: function(e) {
+    this.props.game.startMatch('p')
+  },
 
 }))
 
@@ -8,6 +8,53 @@ export function wsAddressWithPath(path) {
 	return uri
 }
 
+// arena is ?arena= of the page, empty for the default arena
+export function arena() {
+	let m = /[?&]arena=([^&]*)/.exec(window.location.search)
+	return m ? decodeURIComponent(m[1]) : ''
+}
+
+export function withArena(path) {
+	let a = arena()
+	return a ? `${path}?arena=${encodeURIComponent(a)}` : path
+}
+
+// applyStateDelta applies a stateDelta to state in place, del goes before set
+export function applyStateDelta(state, delta) {
+	let split = (p) => p.split('/').slice(1).map(k => k.replace(/~1/g, '/').replace(/~0/g, '~'))
+	for (let p of delta.del) {
+		let keys = split(p)
+		let parent = state
+		for (let k of keys.slice(0, -1)) {
+			parent = parent == null ? null : parent[k]
+		}
+		if (parent == null) {
+			continue
+		}
+		let last = keys[keys.length - 1]
+		if (Array.isArray(parent)) {
+			parent[last] = undefined
+			while (parent.length > 0 && parent[parent.length - 1] === undefined) {
+				parent.pop()
+			}
+		} else {
+			delete parent[last]
+		}
+	}
+	for (let p in delta.set) {
+		let keys = split(p)
+		let parent = state
+		for (let i = 0; i < keys.length - 1; i++) {
+			if (parent[keys[i]] == null) {
+				parent[keys[i]] = /^\d+$/.test(keys[i + 1]) ? [] : {}
+			}
+			parent = parent[keys[i]]
+		}
+		parent[keys[keys.length - 1]] = delta.set[p]
+	}
+	return state
+}
+
 export function timeStr(t, p) {
 	return t.toFixed(p) + 'S'
 }
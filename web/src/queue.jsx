@@ -34,6 +34,7 @@ class Queue {
 				cmd: 'init',
 				ID: 'queue',
 				TYPE: '8',
+				ARENA: util.arena(),
 			}
 			sock.send(JSON.stringify(data))
 		}